@@ -0,0 +1,67 @@
+// Package commands implements the maintenance subcommands of the plugin
+// binary. They run when the binary is invoked directly with arguments,
+// outside of the docker-machine RPC protocol.
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	kubiqo "github.com/francoismeulenberg/docker-machine-driver-kubiqo/driver"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"gc", "delete driver-owned resources not used by any instance", runGC},
+	{"resize-disk", "grow the root disk and filesystem of a machine", runResizeDisk},
+	{"scale", "change the instance type of a machine", runScale},
+	{"snapshot", "create, list or revert to snapshots of a machine", runSnapshot},
+	{"rescue", "boot a machine into or out of the rescue profile", runRescue},
+	{"reinstall", "reset the root disk of a machine to a fresh image", runReinstall},
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit
+// code.
+func Run(args []string) int {
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		if err := cmd.run(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 2
+			}
+			fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q. Available commands:\n", args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-12s %s\n", cmd.name, cmd.usage)
+	}
+	return 2
+}
+
+// apiFlags registers the flags selecting the API credentials on fs and
+// returns a driver that is populated once fs is parsed.
+func apiFlags(fs *flag.FlagSet) *kubiqo.Driver {
+	d := kubiqo.NewDriver("", "").(*kubiqo.Driver)
+
+	fs.StringVar(&d.URL, "url", os.Getenv("EXOSCALE_ENDPOINT"), "exoscale API endpoint")
+	fs.StringVar(&d.APIKey, "api-key", os.Getenv("EXOSCALE_API_KEY"), "exoscale API key")
+	fs.StringVar(&d.APISecretKey, "api-secret-key", os.Getenv("EXOSCALE_API_SECRET_KEY"), "exoscale API secret key")
+	fs.StringVar(&d.CredentialsCommand, "credentials-command", os.Getenv("EXOSCALE_CREDENTIALS_COMMAND"), "command printing a JSON document with the API key and secret")
+	fs.StringVar(&d.CredentialsFile, "credentials-file", os.Getenv("EXOSCALE_CREDENTIALS_FILE"), "path to a JSON file with the API key and secret")
+
+	return d
+}
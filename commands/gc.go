@@ -0,0 +1,100 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	d := apiFlags(fs)
+	zone := fs.String("zone", "", "restrict collection to a zone (default: all zones)")
+	apply := fs.Bool("apply", false, "delete the listed resources instead of only printing the plan")
+	var instances []string
+	fs.Func("instance", "also collect the managed instance with this ID (repeatable)", func(id string) error {
+		instances = append(instances, id)
+		return nil
+	})
+	storagePath := machineStorageFlag(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(instances) > 0 {
+		known, err := knownInstances(*storagePath)
+		if err != nil {
+			return err
+		}
+		for _, id := range instances {
+			if machine, ok := known[id]; ok {
+				return fmt.Errorf("instance %s is machine %s of the store, remove the machine instead", id, machine)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	orphans, err := d.FindOrphans(ctx, *zone, instances)
+	if err != nil {
+		return err
+	}
+
+	if len(orphans) == 0 {
+		fmt.Println("No orphaned resources found.")
+		return nil
+	}
+
+	for _, orphan := range orphans {
+		fmt.Println(orphan)
+	}
+
+	if !*apply {
+		fmt.Printf("%d orphaned resources found, run again with --apply to delete them.\n", len(orphans))
+		return nil
+	}
+
+	for _, orphan := range orphans {
+		fmt.Printf("Deleting %s %s...\n", orphan.Kind, orphan.Name)
+		if err := orphan.Delete(ctx); err != nil {
+			return fmt.Errorf("unable to delete %s %s: %w", orphan.Kind, orphan.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// knownInstances maps the instance IDs of the kubiqo machines in the store
+// to their machine name.
+func knownInstances(storagePath string) (map[string]string, error) {
+	configs, err := filepath.Glob(filepath.Join(storagePath, "machines", "*", "config.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]string, len(configs))
+	for _, config := range configs {
+		data, err := os.ReadFile(config)
+		if err != nil {
+			return nil, err
+		}
+
+		var host struct {
+			DriverName string
+			Driver     struct {
+				MachineName string
+				ID          string `json:"Id"`
+			}
+		}
+		if err := json.Unmarshal(data, &host); err != nil {
+			return nil, fmt.Errorf("error unmarshalling %s: %w", config, err)
+		}
+		if host.DriverName == "kubiqo" && host.Driver.ID != "" {
+			known[host.Driver.ID] = host.Driver.MachineName
+		}
+	}
+
+	return known, nil
+}
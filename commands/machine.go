@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/machine/commands/mcndirs"
+	kubiqo "github.com/francoismeulenberg/docker-machine-driver-kubiqo/driver"
+)
+
+// machine is a host of the docker-machine store driven by this driver.
+type machine struct {
+	Name   string
+	Path   string
+	Driver *kubiqo.Driver
+}
+
+// machineStorageFlag registers the flag locating the machine store on fs.
+func machineStorageFlag(fs *flag.FlagSet) *string {
+	return fs.String("storage-path", mcndirs.GetBaseDir(), "docker-machine store path")
+}
+
+// machineFlags registers the flag locating the machine store on fs. The
+// returned function parses args and loads the machine named by the only
+// positional argument.
+func machineFlags(fs *flag.FlagSet) func(args []string) (*machine, error) {
+	storagePath := machineStorageFlag(fs)
+
+	return func(args []string) (*machine, error) {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() != 1 {
+			return nil, fmt.Errorf("usage: %s [options] MACHINE", fs.Name())
+		}
+
+		return loadMachine(*storagePath, fs.Arg(0))
+	}
+}
+
+func loadMachine(storagePath, name string) (*machine, error) {
+	path := filepath.Join(storagePath, "machines", name, "config.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load machine %s: %w", name, err)
+	}
+
+	var host struct {
+		DriverName string
+		Driver     json.RawMessage
+	}
+	if err := json.Unmarshal(data, &host); err != nil {
+		return nil, fmt.Errorf("error unmarshalling %s: %w", path, err)
+	}
+
+	d := kubiqo.NewDriver(name, storagePath).(*kubiqo.Driver)
+	if host.DriverName != d.DriverName() {
+		return nil, fmt.Errorf("machine %s uses the %s driver", name, host.DriverName)
+	}
+	if err := d.UnmarshalJSON(host.Driver); err != nil {
+		return nil, err
+	}
+
+	return &machine{Name: name, Path: path, Driver: d}, nil
+}
+
+// credentialKeys are the driver config keys holding API credentials. The
+// driver fills them from the environment when loaded, save keeps the stored
+// values so that such credentials are never written to disk.
+var credentialKeys = []string{"ApiKey", "ApiSecretKey"}
+
+// save writes the driver config back to the machine store, leaving the rest
+// of the host config and the stored credentials untouched.
+func (m *machine) save() error {
+	data, err := os.ReadFile(m.Path)
+	if err != nil {
+		return err
+	}
+
+	var host map[string]json.RawMessage
+	if err := json.Unmarshal(data, &host); err != nil {
+		return fmt.Errorf("error unmarshalling %s: %w", m.Path, err)
+	}
+
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(host["Driver"], &stored); err != nil {
+		return fmt.Errorf("error unmarshalling driver config of %s: %w", m.Path, err)
+	}
+
+	driverData, err := json.Marshal(m.Driver)
+	if err != nil {
+		return err
+	}
+
+	var driver map[string]json.RawMessage
+	if err := json.Unmarshal(driverData, &driver); err != nil {
+		return err
+	}
+
+	for _, key := range credentialKeys {
+		if value, ok := stored[key]; ok {
+			driver[key] = value
+		} else {
+			delete(driver, key)
+		}
+	}
+
+	if host["Driver"], err = json.Marshal(driver); err != nil {
+		return err
+	}
+
+	if data, err = json.MarshalIndent(host, "", "    "); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(m.Path)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(m.Path, data, info.Mode().Perm())
+}
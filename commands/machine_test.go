@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeMachine stores a kubiqo machine config under storagePath.
+func writeMachine(t *testing.T, storagePath, name, driver string) {
+	t.Helper()
+
+	dir := filepath.Join(storagePath, "machines", name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	config := `{"ConfigVersion": 3, "DriverName": "kubiqo", "HostOptions": {"Memory": 0}, "Driver": ` + driver + `}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// storedDriver returns the driver config saved for name.
+func storedDriver(t *testing.T, storagePath, name string) (map[string]any, map[string]json.RawMessage) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(storagePath, "machines", name, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var host map[string]json.RawMessage
+	if err := json.Unmarshal(data, &host); err != nil {
+		t.Fatal(err)
+	}
+
+	var driver map[string]any
+	if err := json.Unmarshal(host["Driver"], &driver); err != nil {
+		t.Fatal(err)
+	}
+
+	return driver, host
+}
+
+func TestSaveKeepsStoredCredentials(t *testing.T) {
+	t.Setenv("EXOSCALE_API_KEY", "EXOenv")
+	t.Setenv("EXOSCALE_API_SECRET_KEY", "env-secret")
+
+	storagePath := t.TempDir()
+	writeMachine(t, storagePath, "node-1", `{"MachineName": "node-1", "ApiKey": "EXOstored", "ApiSecretKey": "stored-secret", "DiskSize": 50}`)
+
+	m, err := loadMachine(storagePath, "node-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Commands override the loaded credentials with --api-key and friends.
+	m.Driver.APIKey = "EXOflag"
+	m.Driver.DiskSize = 100
+	if err := m.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, host := storedDriver(t, storagePath, "node-1")
+	if driver["ApiKey"] != "EXOstored" || driver["ApiSecretKey"] != "stored-secret" {
+		t.Errorf("stored credentials = %v/%v, want them unchanged", driver["ApiKey"], driver["ApiSecretKey"])
+	}
+	if driver["DiskSize"] != float64(100) {
+		t.Errorf("DiskSize = %v, want the updated 100", driver["DiskSize"])
+	}
+	var hostOptions map[string]any
+	if err := json.Unmarshal(host["HostOptions"], &hostOptions); err != nil || hostOptions["Memory"] != float64(0) {
+		t.Errorf("HostOptions = %s, want it preserved", host["HostOptions"])
+	}
+}
+
+func TestSaveDoesNotWriteEnvironmentCredentials(t *testing.T) {
+	t.Setenv("EXOSCALE_API_KEY", "EXOenv")
+	t.Setenv("EXOSCALE_API_SECRET_KEY", "env-secret")
+
+	storagePath := t.TempDir()
+	writeMachine(t, storagePath, "node-2", `{"MachineName": "node-2", "CredentialsCommand": "vault kv get -format=json secret/exoscale"}`)
+
+	m, err := loadMachine(storagePath, "node-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Driver.APIKey != "EXOenv" {
+		t.Fatalf("loaded ApiKey = %q, want the environment one", m.Driver.APIKey)
+	}
+	if err := m.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, _ := storedDriver(t, storagePath, "node-2")
+	for _, key := range credentialKeys {
+		if value, ok := driver[key]; ok {
+			t.Errorf("%s = %v was written to the store", key, value)
+		}
+	}
+}
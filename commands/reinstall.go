@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+)
+
+func runReinstall(args []string) error {
+	fs := flag.NewFlagSet("reinstall", flag.ContinueOnError)
+	image := fs.String("image", "", "image to reinstall with (default: the current image)")
+	load := machineFlags(fs)
+
+	m, err := load(args)
+	if err != nil {
+		return err
+	}
+
+	// The disk may have been reset even if SSH did not come back, persist
+	// the new image in any case.
+	reinstallErr := m.Driver.Reinstall(*image)
+	if err := m.save(); err != nil {
+		return err
+	}
+	if reinstallErr != nil {
+		return reinstallErr
+	}
+
+	fmt.Printf("%s reinstalled with %s.\n", m.Name, m.Driver.Image)
+	return nil
+}
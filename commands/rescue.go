@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+func runRescue(args []string) error {
+	usage := errors.New("usage: rescue enter|exit [options] MACHINE")
+	if len(args) == 0 {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("rescue "+args[0], flag.ContinueOnError)
+	profile := fs.String("profile", "", "rescue profile, netboot or netboot-efi (default: based on the image boot mode)")
+	load := machineFlags(fs)
+
+	switch args[0] {
+	case "enter":
+		m, err := load(args[1:])
+		if err != nil {
+			return err
+		}
+
+		// Save even on failure, the instance may have been stopped.
+		rescueErr := m.Driver.EnterRescue(*profile)
+		if err := m.save(); err != nil {
+			return err
+		}
+		if rescueErr != nil {
+			return rescueErr
+		}
+
+		fmt.Printf("%s booted in rescue mode.\n", m.Name)
+		return nil
+	case "exit":
+		m, err := load(args[1:])
+		if err != nil {
+			return err
+		}
+
+		rescueErr := m.Driver.ExitRescue()
+		if err := m.save(); err != nil {
+			return err
+		}
+		if rescueErr != nil {
+			return rescueErr
+		}
+
+		fmt.Printf("%s booted normally.\n", m.Name)
+		return nil
+	}
+
+	return usage
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+func runResizeDisk(args []string) error {
+	fs := flag.NewFlagSet("resize-disk", flag.ContinueOnError)
+	size := fs.Int64("size", 0, "new disk size in GiB")
+	stop := fs.Bool("stop", false, "stop the instance during the resize")
+	load := machineFlags(fs)
+
+	m, err := load(args)
+	if err != nil {
+		return err
+	}
+	if *size == 0 {
+		return errors.New("--size is required")
+	}
+
+	// The disk may have been resized even if growing the filesystem failed,
+	// persist the new size in any case.
+	resizeErr := m.Driver.ResizeDisk(*size, *stop)
+	if err := m.save(); err != nil {
+		return err
+	}
+	if resizeErr != nil {
+		return resizeErr
+	}
+
+	fmt.Printf("Disk of %s resized to %d GiB.\n", m.Name, *size)
+	return nil
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
+func runScale(args []string) error {
+	fs := flag.NewFlagSet("scale", flag.ContinueOnError)
+	profile := fs.String("profile", "", "target instance profile (Small, Medium, standard.large, ...)")
+	load := machineFlags(fs)
+
+	m, err := load(args)
+	if err != nil {
+		return err
+	}
+	if *profile == "" {
+		return errors.New("--profile is required")
+	}
+
+	// The instance type may have changed even if it failed to come back up,
+	// persist the new profile in any case.
+	scaleErr := m.Driver.Scale(*profile)
+	if err := m.save(); err != nil {
+		return err
+	}
+	if scaleErr != nil {
+		return scaleErr
+	}
+
+	fmt.Printf("%s scaled to %s.\n", m.Name, m.Driver.InstanceProfile)
+	return nil
+}
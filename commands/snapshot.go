@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+func runSnapshot(args []string) error {
+	usage := errors.New("usage: snapshot create|list|revert [options] MACHINE [SNAPSHOT]")
+	if len(args) == 0 {
+		return usage
+	}
+
+	fs := flag.NewFlagSet("snapshot "+args[0], flag.ContinueOnError)
+	storagePath := machineStorageFlag(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	switch {
+	case args[0] == "create" && fs.NArg() == 1:
+		m, err := loadMachine(*storagePath, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		snapshot, err := m.Driver.CreateSnapshot()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Snapshot %s of %s created.\n", snapshot.ID, m.Name)
+		return nil
+	case args[0] == "list" && fs.NArg() == 1:
+		m, err := loadMachine(*storagePath, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		snapshots, err := m.Driver.ListSnapshots()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tCREATED\tSIZE\tSTATE")
+		for _, snapshot := range snapshots {
+			fmt.Fprintf(w, "%s\t%s\t%d GiB\t%s\n", snapshot.ID, snapshot.CreatedAT.Format(time.RFC3339), snapshot.Size, snapshot.State)
+		}
+		return w.Flush()
+	case args[0] == "revert" && fs.NArg() == 2:
+		m, err := loadMachine(*storagePath, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		if err := m.Driver.RevertToSnapshot(v3.UUID(fs.Arg(1))); err != nil {
+			return err
+		}
+
+		fmt.Printf("%s reverted to snapshot %s.\n", m.Name, fs.Arg(1))
+		return nil
+	}
+
+	return usage
+}
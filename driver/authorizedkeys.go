@@ -0,0 +1,129 @@
+package kubiqo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	authorizedKeysTimeout = 30 * time.Second
+	maxAuthorizedKeysSize = 1 << 20
+)
+
+// resolveAuthorizedKeys returns the public keys of the --exoscale-authorized-key
+// values. A value is either a public key, an HTTPS URL serving
+// authorized_keys content (e.g. https://github.com/<user>.keys) or the path
+// of such a file.
+func (d *Driver) resolveAuthorizedKeys(ctx context.Context) ([][]byte, error) {
+	var keys [][]byte
+	for _, value := range d.AuthorizedKeys {
+		if value == "" {
+			continue
+		}
+
+		content, err := authorizedKeysContent(ctx, value)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read authorized key %s: %w", value, err)
+		}
+
+		parsed, err := parseAuthorizedKeys(content)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorized key %s: %w", value, err)
+		}
+		if len(parsed) == 0 {
+			return nil, fmt.Errorf("no public key found in %s", value)
+		}
+
+		keys = append(keys, parsed...)
+	}
+
+	return keys, nil
+}
+
+func authorizedKeysContent(ctx context.Context, value string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(value, "https://"):
+		return fetchAuthorizedKeys(ctx, value)
+	case strings.Contains(value, "://"):
+		// Anyone on the network path could inject a key over plain HTTP.
+		return nil, errors.New("only https:// URLs are supported")
+	case strings.Contains(value, " "):
+		// Public keys always have a type and a base64 blob separated by a
+		// space, which paths seldom have.
+		return []byte(value), nil
+	case strings.HasPrefix(value, "~/"):
+		usr, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		return os.ReadFile(filepath.Join(usr.HomeDir, value[2:]))
+	default:
+		return os.ReadFile(value)
+	}
+}
+
+func fetchAuthorizedKeys(ctx context.Context, url string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, authorizedKeysTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(resp.Body, maxAuthorizedKeysSize))
+}
+
+// parseAuthorizedKeys returns the keys of authorized_keys content, one per
+// line, skipping blank lines and comments.
+func parseAuthorizedKeys(content []byte) ([][]byte, error) {
+	var keys [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		if _, _, _, _, err := gossh.ParseAuthorizedKey(line); err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, bytes.Clone(line))
+	}
+
+	return keys, scanner.Err()
+}
+
+// appendAuthorizedKeys adds pubKeys to the ssh_authorized_keys of cloudInit.
+func appendAuthorizedKeys(cloudInit []byte, pubKeys ...[]byte) ([]byte, error) {
+	items := make([]*yaml.Node, 0, len(pubKeys))
+	for _, pubKey := range pubKeys {
+		items = append(items, scalarNode(string(bytes.TrimSpace(pubKey))))
+	}
+
+	return appendCloudConfigLists(cloudInit, cloudConfigList{key: "ssh_authorized_keys", items: items})
+}
@@ -0,0 +1,132 @@
+package kubiqo
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// newPublicKey returns a new ed25519 public key in authorized_keys format.
+func newPublicKey(t *testing.T, comment string) string {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(gossh.MarshalAuthorizedKey(key))) + " " + comment
+}
+
+func TestParseAuthorizedKeys(t *testing.T) {
+	alice, bob := newPublicKey(t, "alice"), newPublicKey(t, "bob")
+
+	keys, err := parseAuthorizedKeys([]byte("# on-call\n\n  " + alice + "  \n" + bob + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || string(keys[0]) != alice || string(keys[1]) != bob {
+		t.Errorf("parseAuthorizedKeys() = %q, want alice and bob", keys)
+	}
+
+	if _, err := parseAuthorizedKeys([]byte(alice + "\nssh-rsa not-base64\n")); err == nil {
+		t.Error("parseAuthorizedKeys() accepted an invalid key")
+	}
+}
+
+func TestResolveAuthorizedKeys(t *testing.T) {
+	inline, fromFile, fromURL := newPublicKey(t, "inline"), newPublicKey(t, "file"), newPublicKey(t, "url")
+
+	path := filepath.Join(t.TempDir(), "authorized_keys")
+	if err := os.WriteFile(path, []byte(fromFile+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, fromURL)
+	}))
+	defer srv.Close()
+
+	// fetchAuthorizedKeys uses the default client, trust the test server.
+	transport := http.DefaultClient.Transport
+	http.DefaultClient.Transport = srv.Client().Transport
+	defer func() { http.DefaultClient.Transport = transport }()
+
+	d := &Driver{AuthorizedKeys: []string{inline, "", path, srv.URL + "/alice.keys"}}
+	keys, err := d.resolveAuthorizedKeys(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, key := range keys {
+		got = append(got, string(key))
+	}
+	if want := []string{inline, fromFile, fromURL}; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("resolveAuthorizedKeys() = %q, want %q", got, want)
+	}
+}
+
+func TestResolveAuthorizedKeysErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for value, want := range map[string]string{
+		"http://github.com/alice.keys":          "only https://",
+		"ftp://example.com/keys":                "only https://",
+		filepath.Join(t.TempDir(), "missing"):   "unable to read",
+		empty:                                   "no public key",
+		"ssh-ed25519 AAAA-truncated alice@home": "invalid authorized key",
+	} {
+		d := &Driver{AuthorizedKeys: []string{value}}
+		if _, err := d.resolveAuthorizedKeys(context.Background()); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("resolveAuthorizedKeys(%q) error = %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestAppendAuthorizedKeys(t *testing.T) {
+	alice, bob := newPublicKey(t, "alice"), newPublicKey(t, "bob")
+
+	got, err := appendAuthorizedKeys([]byte(defaultCloudInit), []byte(alice+"\n"), []byte(bob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := defaultCloudInit + "\nssh_authorized_keys:\n  - " + alice + "\n  - " + bob + "\n"; string(got) != want {
+		t.Errorf("appendAuthorizedKeys() = %q, want %q", got, want)
+	}
+
+	// A user list is extended in place, without repeating its keys.
+	userData := "#cloud-config\nssh_authorized_keys:\n  - " + alice + "\npackages:\n  - curl\n"
+	got, err = appendAuthorizedKeys([]byte(userData), []byte(alice), []byte(bob))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "#cloud-config\nssh_authorized_keys:\n  - " + alice + "\n  - " + bob + "\npackages:\n  - curl\n"; string(got) != want {
+		t.Errorf("appendAuthorizedKeys() = %q, want %q", got, want)
+	}
+
+	for _, userData := range []string{
+		"#cloud-config\nssh_authorized_keys: " + alice + "\n",
+		"#cloud-config\nssh_authorized_keys:\n- a\n  b: [\n",
+	} {
+		if _, err := appendAuthorizedKeys([]byte(userData), []byte(bob)); err == nil {
+			t.Errorf("appendAuthorizedKeys(%q) succeeded, want an error", userData)
+		}
+	}
+}
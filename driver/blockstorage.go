@@ -0,0 +1,194 @@
+package kubiqo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	v3 "github.com/exoscale/egoscale/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// blockVolume is a parsed --exoscale-block-volume value.
+type blockVolume struct {
+	Size int64
+	Name string
+}
+
+// parseBlockVolumes parses size[:name] values. Volumes without a name are
+// named after the machine and their position.
+func parseBlockVolumes(machineName string, values []string) ([]blockVolume, error) {
+	volumes := make([]blockVolume, 0, len(values))
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+
+		sizeStr, name, _ := strings.Cut(value, ":")
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid block volume %q, expected size[:name] with a size in GiB", value)
+		}
+		if name == "" {
+			name = fmt.Sprintf("%s-data-%d", machineName, len(volumes))
+		}
+
+		volumes = append(volumes, blockVolume{Size: size, Name: name})
+	}
+
+	return volumes, nil
+}
+
+// blockVolumeDevice returns the device name of the i-th attached volume,
+// volumes are exposed in attachment order after the root disk (/dev/vda).
+func blockVolumeDevice(i int) string {
+	return fmt.Sprintf("/dev/vd%c", 'b'+i)
+}
+
+// fsSetup is a cloud-init fs_setup entry.
+type fsSetup struct {
+	Device     string `yaml:"device"`
+	Filesystem string `yaml:"filesystem"`
+	Overwrite  bool   `yaml:"overwrite"`
+}
+
+// appendBlockVolumesCloudInit adds to cloudInit the directives formatting and
+// mounting the block volumes under /mnt/<name>, unless no filesystem was
+// requested.
+func (d *Driver) appendBlockVolumesCloudInit(cloudInit []byte) ([]byte, error) {
+	if d.BlockVolumeFilesystem == "" || len(d.BlockVolumes) == 0 {
+		return cloudInit, nil
+	}
+
+	volumes, err := parseBlockVolumes(d.MachineName, d.BlockVolumes)
+	if err != nil {
+		return nil, err
+	}
+
+	var fsSetups, mounts []*yaml.Node
+	for i, volume := range volumes {
+		device := blockVolumeDevice(i)
+
+		var setup yaml.Node
+		if err := setup.Encode(fsSetup{Device: device, Filesystem: d.BlockVolumeFilesystem}); err != nil {
+			return nil, err
+		}
+		fsSetups = append(fsSetups, &setup)
+
+		mount := sequenceNode([]*yaml.Node{
+			scalarNode(device),
+			scalarNode("/mnt/" + volume.Name),
+			scalarNode("auto"),
+			scalarNode("defaults,nofail"),
+		})
+		mount.Style = yaml.FlowStyle
+		mounts = append(mounts, mount)
+	}
+
+	return appendCloudConfigLists(cloudInit,
+		cloudConfigList{key: "fs_setup", items: fsSetups},
+		cloudConfigList{key: "mounts", items: mounts},
+	)
+}
+
+// createBlockVolumes creates the requested volumes and records their IDs so
+// that Remove can clean them up even when Create fails halfway.
+func (d *Driver) createBlockVolumes(ctx context.Context, client *v3.Client) error {
+	volumes, err := parseBlockVolumes(d.MachineName, d.BlockVolumes)
+	if err != nil {
+		return err
+	}
+
+	for _, volume := range volumes {
+		log.Infof("Creating block storage volume %s (%d GiB)...", volume.Name, volume.Size)
+
+		op, err := client.CreateBlockStorageVolume(ctx, v3.CreateBlockStorageVolumeRequest{
+			Name:   volume.Name,
+			Size:   volume.Size,
+			Labels: d.resourceLabels(),
+		})
+		if err != nil {
+			return err
+		}
+
+		res, err := client.Wait(ctx, op, v3.OperationStateSuccess)
+		if err != nil {
+			return err
+		}
+
+		d.BlockVolumeIDs = append(d.BlockVolumeIDs, res.Reference.ID)
+	}
+
+	return nil
+}
+
+// attachBlockVolumes attaches the created volumes to the instance, in order.
+func (d *Driver) attachBlockVolumes(ctx context.Context, client *v3.Client) error {
+	for _, id := range d.BlockVolumeIDs {
+		log.Debugf("Attaching block storage volume %s", id)
+
+		op, err := client.AttachBlockStorageVolumeToInstance(ctx, id, v3.AttachBlockStorageVolumeToInstanceRequest{
+			Instance: &v3.InstanceTarget{ID: d.ID},
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeBlockVolumes detaches the volumes and deletes them unless they are
+// retained. The volumes that could not be removed are kept for a later
+// attempt.
+func (d *Driver) removeBlockVolumes(ctx context.Context, client *v3.Client) error {
+	var (
+		remaining []v3.UUID
+		errs      []error
+	)
+
+	for _, id := range d.BlockVolumeIDs {
+		if err := d.removeBlockVolume(ctx, client, id); err != nil {
+			remaining = append(remaining, id)
+			errs = append(errs, fmt.Errorf("unable to remove block storage volume %s: %w", id, err))
+		}
+	}
+
+	d.BlockVolumeIDs = remaining
+
+	return errors.Join(errs...)
+}
+
+func (d *Driver) removeBlockVolume(ctx context.Context, client *v3.Client, id v3.UUID) error {
+	volume, err := client.GetBlockStorageVolume(ctx, id)
+	if err != nil {
+		return ignoreNotFound(err)
+	}
+
+	if volume.Instance != nil {
+		log.Debugf("Detaching block storage volume %s", id)
+
+		err := deleteResource(ctx, client, func() (*v3.Operation, error) {
+			return client.DetachBlockStorageVolume(ctx, id)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if d.RetainBlockVolumes {
+		log.Infof("Block storage volume %s (%s) was retained", volume.Name, id)
+		return nil
+	}
+
+	return deleteResource(ctx, client, func() (*v3.Operation, error) {
+		return client.DeleteBlockStorageVolume(ctx, id)
+	})
+}
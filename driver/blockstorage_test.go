@@ -0,0 +1,113 @@
+package kubiqo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func TestParseBlockVolumes(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []blockVolume
+	}{
+		{[]string{"10", "20"}, []blockVolume{{10, "node-1-data-0"}, {20, "node-1-data-1"}}},
+		{[]string{"100:etcd", "50"}, []blockVolume{{100, "etcd"}, {50, "node-1-data-1"}}},
+		{[]string{"", "10:"}, []blockVolume{{10, "node-1-data-0"}}},
+	}
+	for _, tt := range tests {
+		got, err := parseBlockVolumes("node-1", tt.values)
+		if err != nil {
+			t.Errorf("parseBlockVolumes(%q) error = %v", tt.values, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseBlockVolumes(%q) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+
+	for _, value := range []string{"ten", "0:etcd", "-10"} {
+		if _, err := parseBlockVolumes("node-1", []string{value}); err == nil {
+			t.Errorf("parseBlockVolumes(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestBlockVolumeDevice(t *testing.T) {
+	for index, want := range map[int]string{0: "/dev/vdb", 1: "/dev/vdc", 24: "/dev/vdz"} {
+		if got := blockVolumeDevice(index); got != want {
+			t.Errorf("blockVolumeDevice(%d) = %q, want %q", index, got, want)
+		}
+	}
+}
+
+func TestAppendBlockVolumesCloudInit(t *testing.T) {
+	d := &Driver{
+		BaseDriver:            &drivers.BaseDriver{MachineName: "node-1"},
+		BlockVolumes:          []string{"10", "20:etcd"},
+		BlockVolumeFilesystem: "xfs",
+	}
+
+	t.Run("appended", func(t *testing.T) {
+		got, err := d.appendBlockVolumesCloudInit([]byte(defaultCloudInit))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := defaultCloudInit + `
+fs_setup:
+  - device: /dev/vdb
+    filesystem: xfs
+    overwrite: false
+  - device: /dev/vdc
+    filesystem: xfs
+    overwrite: false
+mounts:
+  - [/dev/vdb, /mnt/node-1-data-0, auto, 'defaults,nofail']
+  - [/dev/vdc, /mnt/etcd, auto, 'defaults,nofail']
+`
+		if string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("merged into the user mounts", func(t *testing.T) {
+		userData := "#cloud-config\nmounts:\n  - [swap, none, swap, sw]\n"
+		got, err := d.appendBlockVolumesCloudInit([]byte(userData))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := `#cloud-config
+mounts:
+  - [swap, none, swap, sw]
+  - [/dev/vdb, /mnt/node-1-data-0, auto, 'defaults,nofail']
+  - [/dev/vdc, /mnt/etcd, auto, 'defaults,nofail']
+fs_setup:
+  - device: /dev/vdb
+    filesystem: xfs
+    overwrite: false
+  - device: /dev/vdc
+    filesystem: xfs
+    overwrite: false
+`
+		if string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("user fs_setup is not a list", func(t *testing.T) {
+		if _, err := d.appendBlockVolumesCloudInit([]byte("#cloud-config\nfs_setup: none\n")); err == nil {
+			t.Error("want an error")
+		}
+	})
+
+	t.Run("no filesystem", func(t *testing.T) {
+		unformatted := *d
+		unformatted.BlockVolumeFilesystem = ""
+
+		got, err := unformatted.appendBlockVolumesCloudInit([]byte(defaultCloudInit))
+		if err != nil || string(got) != defaultCloudInit {
+			t.Errorf("got %q, %v, want the user data unchanged", got, err)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package kubiqo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const cloudConfigHeader = "#cloud-config"
+
+// cloudConfigList is a top-level list of a cloud-config document.
+type cloudConfigList struct {
+	key   string
+	items []*yaml.Node
+}
+
+// appendCloudConfigLists adds items to the top-level lists of cloudInit.
+// Lists already declared, typically by --exoscale-userdata, are merged into
+// since cloud-init would only keep one of two top-level keys. Scalar items
+// already present are not repeated.
+func appendCloudConfigLists(cloudInit []byte, lists ...cloudConfigList) ([]byte, error) {
+	var declared bool
+	for _, list := range lists {
+		declared = declared || hasTopLevelKey(cloudInit, list.key)
+	}
+
+	// Leave the document untouched when there is nothing to merge.
+	if !declared {
+		root := &yaml.Node{Kind: yaml.MappingNode}
+		for _, list := range lists {
+			if len(list.items) > 0 {
+				root.Content = append(root.Content, scalarNode(list.key), sequenceNode(list.items))
+			}
+		}
+		if len(root.Content) == 0 {
+			return cloudInit, nil
+		}
+
+		out, err := encodeYAML(root)
+		if err != nil {
+			return nil, err
+		}
+
+		return append(append(cloudInit, '\n'), out...), nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(cloudInit, &doc); err != nil {
+		return nil, fmt.Errorf("unable to merge into the cloud-init user data: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("unable to merge into the cloud-init user data: it is not a mapping")
+	}
+
+	root := doc.Content[0]
+	for _, list := range lists {
+		if len(list.items) == 0 {
+			continue
+		}
+
+		seq := lookupKey(root, list.key)
+		if seq == nil {
+			root.Content = append(root.Content, scalarNode(list.key), sequenceNode(list.items))
+			continue
+		}
+		if seq.Kind != yaml.SequenceNode {
+			return nil, fmt.Errorf("unable to merge into the cloud-init user data: %s is not a list", list.key)
+		}
+
+		for _, item := range list.items {
+			if item.Kind == yaml.ScalarNode && containsScalar(seq, item.Value) {
+				continue
+			}
+			seq.Content = append(seq.Content, item)
+		}
+	}
+
+	merged, err := encodeYAML(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	// cloud-init requires the header, keep it even if the encoder did not.
+	if bytes.HasPrefix(cloudInit, []byte(cloudConfigHeader)) && !bytes.HasPrefix(merged, []byte(cloudConfigHeader)) {
+		merged = append([]byte(cloudConfigHeader+"\n"), merged...)
+	}
+
+	return merged, nil
+}
+
+// hasTopLevelKey reports whether the YAML document declares key at the top
+// level, without parsing it.
+func hasTopLevelKey(document []byte, key string) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(document))
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), key+":") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func lookupKey(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+func containsScalar(seq *yaml.Node, value string) bool {
+	for _, item := range seq.Content {
+		if item.Kind == yaml.ScalarNode && strings.TrimSpace(item.Value) == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func sequenceNode(items []*yaml.Node) *yaml.Node {
+	return &yaml.Node{Kind: yaml.SequenceNode, Content: items}
+}
+
+func encodeYAML(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
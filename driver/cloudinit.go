@@ -0,0 +1,70 @@
+package kubiqo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	defaultCloudInitTimeout = 600
+
+	// cloud-init status exits with 2 when done with recoverable errors,
+	// such as deprecated keys, since cloud-init 23.4.
+	cloudInitRecoverableExit = 2
+	// timeout exits with 124 when the command timed out.
+	timeoutExit = 124
+
+	cloudInitExitMarker = "cloud-init-exit="
+)
+
+// waitForCloudInit blocks until cloud-init has finished on the instance, or
+// CloudInitTimeout seconds have elapsed. On failure, the cloud-init status
+// and the tail of its output log are returned in the error.
+func (d *Driver) waitForCloudInit() error {
+	log.Infof("Waiting for cloud-init to finish on %s...", d.MachineName)
+
+	// Echo the exit code so that it can be told apart from SSH failures.
+	command := fmt.Sprintf("sudo timeout %d cloud-init status --wait; echo %s$?", d.CloudInitTimeout, cloudInitExitMarker)
+	out, err := drivers.RunSSHCommandFromDriver(d, command)
+	if err != nil {
+		return fmt.Errorf("unable to check cloud-init on %s: %w", d.MachineName, err)
+	}
+
+	code, err := cloudInitExitCode(out)
+	if err != nil {
+		return err
+	}
+
+	var reason string
+	switch code {
+	case 0:
+		log.Infof("cloud-init finished on %s", d.MachineName)
+		return nil
+	case cloudInitRecoverableExit:
+		log.Warnf("cloud-init finished on %s with recoverable errors, see cloud-init status --long", d.MachineName)
+		return nil
+	case timeoutExit:
+		reason = fmt.Sprintf("did not complete within %ds", d.CloudInitTimeout)
+	default:
+		reason = fmt.Sprintf("failed with exit code %d", code)
+	}
+
+	status, _ := drivers.RunSSHCommandFromDriver(d, "sudo cloud-init status --long")
+	output, _ := drivers.RunSSHCommandFromDriver(d, "sudo tail -n 50 /var/log/cloud-init-output.log")
+
+	return fmt.Errorf("cloud-init %s:\n%s\n%s", reason, strings.TrimSpace(status), strings.TrimSpace(output))
+}
+
+// cloudInitExitCode extracts the exit code echoed after the marker.
+func cloudInitExitCode(out string) (int, error) {
+	i := strings.LastIndex(out, cloudInitExitMarker)
+	if i < 0 {
+		return 0, fmt.Errorf("unable to read the cloud-init exit code from %q", out)
+	}
+
+	return strconv.Atoi(strings.TrimSpace(out[i+len(cloudInitExitMarker):]))
+}
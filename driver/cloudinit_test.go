@@ -0,0 +1,30 @@
+package kubiqo
+
+import "testing"
+
+func TestCloudInitExitCode(t *testing.T) {
+	outputs := map[string]int{
+		"status: done\ncloud-init-exit=0\n":                                    0,
+		"status: degraded done\n" + cloudInitExitMarker + "2\n":                cloudInitRecoverableExit,
+		"........\n" + cloudInitExitMarker + "124":                             timeoutExit,
+		"cloud-init-exit=9 is in the logs\nstatus: error\ncloud-init-exit=1\n": 1,
+	}
+
+	for out, want := range outputs {
+		got, err := cloudInitExitCode(out)
+		if err != nil {
+			t.Errorf("cloudInitExitCode(%q) error = %v", out, err)
+		} else if got != want {
+			t.Errorf("cloudInitExitCode(%q) = %d, want %d", out, got, want)
+		}
+	}
+}
+
+func TestCloudInitExitCodeMissing(t *testing.T) {
+	// e.g. the SSH session was cut before the echo ran.
+	for _, out := range []string{"", "status: running\n", "cloud-init-exit=\n"} {
+		if code, err := cloudInitExitCode(out); err == nil {
+			t.Errorf("cloudInitExitCode(%q) = %d, want an error", out, code)
+		}
+	}
+}
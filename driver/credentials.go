@@ -0,0 +1,119 @@
+package kubiqo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	credentialsCommandTimeout = 30 * time.Second
+	// credentialsCacheTTL spares running the credentials command for each
+	// of the many clients a single driver operation creates.
+	credentialsCacheTTL = time.Minute
+)
+
+// apiCredentials is the document expected from a credentials command or
+// file. It may be given as-is, or nested under "data" (Vault KV v1) or
+// "data.data" (Vault KV v2) so that `vault kv get -format=json` can be used
+// directly as a credentials command.
+type apiCredentials struct {
+	Key    string `json:"key"`
+	Secret string `json:"secret"`
+}
+
+// credentials returns the API key and secret to use for the next client.
+// External sources are consulted again so that rotated keys are picked up
+// without touching the persisted machine config. The command output is
+// reused for credentialsCacheTTL.
+func (d *Driver) credentials(ctx context.Context) (string, string, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch {
+	case d.CredentialsCommand != "":
+		if d.commandCredentials != nil && time.Since(d.commandCredentialsAt) < credentialsCacheTTL {
+			return d.commandCredentials.Key, d.commandCredentials.Secret, nil
+		}
+
+		data, err = runCredentialsCommand(ctx, d.CredentialsCommand)
+		if err != nil {
+			return "", "", err
+		}
+	case d.CredentialsFile != "":
+		data, err = os.ReadFile(d.CredentialsFile)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to read credentials file: %w", err)
+		}
+	default:
+		return d.APIKey, d.APISecretKey, nil
+	}
+
+	creds, err := parseCredentials(data)
+	if err != nil {
+		return "", "", err
+	}
+
+	if d.CredentialsCommand != "" {
+		d.commandCredentials = creds
+		d.commandCredentialsAt = time.Now()
+	}
+
+	return creds.Key, creds.Secret, nil
+}
+
+func runCredentialsCommand(ctx context.Context, command string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, credentialsCommandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("credentials command did not complete within %s", credentialsCommandTimeout)
+		}
+		return nil, fmt.Errorf("credentials command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func parseCredentials(data []byte) (*apiCredentials, error) {
+	var doc struct {
+		apiCredentials
+		Data *struct {
+			apiCredentials
+			Data *apiCredentials `json:"data"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error unmarshalling credentials from JSON: %w", err)
+	}
+
+	creds := doc.apiCredentials
+	if doc.Data != nil {
+		if doc.Data.Data != nil {
+			creds = *doc.Data.Data
+		} else if doc.Data.Key != "" {
+			creds = doc.Data.apiCredentials
+		}
+	}
+
+	if creds.Key == "" || creds.Secret == "" {
+		return nil, errors.New(`credentials source did not provide both "key" and "secret"`)
+	}
+
+	return &creds, nil
+}
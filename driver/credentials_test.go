@@ -0,0 +1,103 @@
+package kubiqo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCredentials(t *testing.T) {
+	for name, data := range map[string]string{
+		"flat":        `{"key": "EXOkey", "secret": "s3cr3t"}`,
+		"vault kv v1": `{"lease_duration": 3600, "data": {"key": "EXOkey", "secret": "s3cr3t"}}`,
+		"vault kv v2": `{"data": {"data": {"key": "EXOkey", "secret": "s3cr3t"}, "metadata": {"version": 3}}}`,
+		"kv v2 wins":  `{"key": "top", "secret": "top", "data": {"data": {"key": "EXOkey", "secret": "s3cr3t"}}}`,
+	} {
+		creds, err := parseCredentials([]byte(data))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if creds.Key != "EXOkey" || creds.Secret != "s3cr3t" {
+			t.Errorf("%s: got %q/%q", name, creds.Key, creds.Secret)
+		}
+	}
+
+	for _, data := range []string{
+		`{"key": "EXOkey"}`,
+		`{"data": {"data": {}}}`,
+		`key=EXOkey`,
+	} {
+		if _, err := parseCredentials([]byte(data)); err == nil {
+			t.Errorf("parseCredentials(%s) succeeded, want an error", data)
+		}
+	}
+}
+
+func TestCredentialsFileIsReread(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	d := &Driver{CredentialsFile: path, APIKey: "static", APISecretKey: "static"}
+
+	for _, key := range []string{"EXOfirst", "EXOrotated"} {
+		if err := os.WriteFile(path, []byte(`{"key": "`+key+`", "secret": "s"}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		got, _, err := d.credentials(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != key {
+			t.Errorf("credentials() key = %q, want %q", got, key)
+		}
+	}
+}
+
+func TestCredentialsCommandIsCached(t *testing.T) {
+	count := filepath.Join(t.TempDir(), "count")
+	d := &Driver{
+		CredentialsCommand: `echo run >> ` + count + `; echo '{"key": "EXOkey", "secret": "s3cr3t"}'`,
+	}
+
+	for range 3 {
+		key, secret, err := d.credentials(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "EXOkey" || secret != "s3cr3t" {
+			t.Fatalf("credentials() = %q/%q", key, secret)
+		}
+	}
+
+	runs, err := os.ReadFile(count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(runs), "run"); n != 1 {
+		t.Errorf("the credentials command ran %d times, want 1", n)
+	}
+
+	// An expired entry runs the command again.
+	d.commandCredentialsAt = d.commandCredentialsAt.Add(-credentialsCacheTTL)
+	if _, _, err := d.credentials(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	runs, _ = os.ReadFile(count)
+	if n := strings.Count(string(runs), "run"); n != 2 {
+		t.Errorf("the credentials command ran %d times after expiry, want 2", n)
+	}
+}
+
+func TestCredentialsCommandFailure(t *testing.T) {
+	d := &Driver{CredentialsCommand: "echo 'vault: permission denied' >&2; exit 2"}
+
+	_, _, err := d.credentials(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Errorf("credentials() error = %v, want the command stderr", err)
+	}
+	if d.commandCredentials != nil {
+		t.Error("a failed command must not be cached")
+	}
+}
@@ -0,0 +1,85 @@
+package kubiqo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// growRootFSCommand grows the partition holding / to the end of its disk and
+// then the filesystem itself.
+const growRootFSCommand = `set -e
+ROOT=$(findmnt -n -o SOURCE /)
+DISK=/dev/$(lsblk -no PKNAME "$ROOT")
+PART=$(cat /sys/class/block/$(basename "$ROOT")/partition)
+sudo growpart "$DISK" "$PART" || [ $? -eq 1 ]
+case $(findmnt -n -o FSTYPE /) in
+xfs) sudo xfs_growfs / ;;
+*) sudo resize2fs "$ROOT" ;;
+esac`
+
+// ResizeDisk grows the root disk of the instance to size GiB, then grows
+// the root filesystem over SSH. When stop is set, the instance is stopped
+// for the resize and started again afterwards.
+func (d *Driver) ResizeDisk(size int64, stop bool) error {
+	if size <= d.DiskSize {
+		return fmt.Errorf("invalid disk size %d, it must be larger than the current %d GiB", size, d.DiskSize)
+	}
+	if size > maxDiskSize {
+		return fmt.Errorf("invalid disk size %d, it must be at most %d GiB", size, maxDiskSize)
+	}
+
+	ctx := context.Background()
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	st, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	restart := stop && st == state.Running
+	if restart {
+		log.Infof("Stopping %s...", d.MachineName)
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Resizing the disk of %s to %d GiB...", d.MachineName, size)
+	op, err := client.ResizeInstanceDisk(ctx, d.ID, v3.ResizeInstanceDiskRequest{
+		DiskSize: size,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
+
+	d.DiskSize = size
+
+	if restart {
+		log.Infof("Starting %s...", d.MachineName)
+		if err := d.Start(); err != nil {
+			return err
+		}
+	} else if st != state.Running {
+		log.Infof("%s is not running, the filesystem will be grown on next boot by cloud-init", d.MachineName)
+		return nil
+	}
+
+	log.Infof("Growing the root filesystem...")
+	if _, err := drivers.RunSSHCommandFromDriver(d, growRootFSCommand); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package kubiqo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/docker/machine/libmachine/log"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// hostnameData holds the only fields hostname templates may use, they end
+// up in public DNS records.
+type hostnameData struct {
+	MachineName      string
+	AvailabilityZone string
+}
+
+// renderHostname expands a hostname template such as
+// {{.MachineName}}.nodes.example.com.
+func (d *Driver) renderHostname(text string) (string, error) {
+	tmpl, err := template.New("hostname").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid hostname template %q: %w", text, err)
+	}
+
+	data := hostnameData{
+		MachineName:      d.MachineName,
+		AvailabilityZone: d.AvailabilityZone,
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("invalid hostname template %q: %w", text, err)
+	}
+
+	return strings.TrimSuffix(buf.String(), "."), nil
+}
+
+// setReverseDNS points the PTR records of the instance public addresses to
+// the rendered d.ReverseDNS.
+func (d *Driver) setReverseDNS(ctx context.Context, client *v3.Client) error {
+	domain, err := d.renderHostname(d.ReverseDNS)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Setting reverse DNS of %s to %s...", d.MachineName, domain)
+	op, err := client.UpdateReverseDNSInstance(ctx, d.ID, v3.UpdateReverseDNSInstanceRequest{
+		DomainName: domain,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
+	return err
+}
+
+// clearReverseDNS removes the PTR records of the instance public addresses.
+func (d *Driver) clearReverseDNS(ctx context.Context, client *v3.Client) error {
+	op, err := client.DeleteReverseDNSInstance(ctx, d.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
+	return err
+}
+
+// createDNSRecords registers A and AAAA records named after the machine in
+// d.DNSDomain and records their IDs for Remove.
+func (d *Driver) createDNSRecords(ctx context.Context, client *v3.Client, instance *v3.Instance) error {
+	domains, err := client.ListDNSDomains(ctx)
+	if err != nil {
+		return err
+	}
+
+	domain, err := domains.FindDNSDomain(d.DNSDomain)
+	if err != nil {
+		return fmt.Errorf("unable to find DNS domain %s: %w", d.DNSDomain, err)
+	}
+	d.DNSDomainID = domain.ID
+
+	records := map[v3.CreateDNSDomainRecordRequestType]string{}
+	if instance.PublicIP != nil {
+		records[v3.CreateDNSDomainRecordRequestTypeA] = instance.PublicIP.String()
+	}
+	if instance.Ipv6Address != "" {
+		records[v3.CreateDNSDomainRecordRequestTypeAAAA] = instance.Ipv6Address
+	}
+
+	for recordType, content := range records {
+		log.Infof("Registering %s record %s.%s -> %s...", recordType, d.MachineName, domain.UnicodeName, content)
+
+		op, err := client.CreateDNSDomainRecord(ctx, domain.ID, v3.CreateDNSDomainRecordRequest{
+			Name:    d.MachineName,
+			Type:    recordType,
+			Content: content,
+		})
+		if err != nil {
+			return err
+		}
+
+		res, err := client.Wait(ctx, op, v3.OperationStateSuccess)
+		if err != nil {
+			return err
+		}
+
+		d.DNSRecordIDs = append(d.DNSRecordIDs, res.Reference.ID)
+	}
+
+	return nil
+}
+
+// deleteDNSRecords removes the records registered by createDNSRecords. The
+// records that could not be removed are kept for a later attempt.
+func (d *Driver) deleteDNSRecords(ctx context.Context, client *v3.Client) error {
+	var (
+		remaining []v3.UUID
+		errs      []error
+	)
+
+	for _, id := range d.DNSRecordIDs {
+		err := deleteResource(ctx, client, func() (*v3.Operation, error) {
+			return client.DeleteDNSDomainRecord(ctx, d.DNSDomainID, id)
+		})
+		if err != nil {
+			remaining = append(remaining, id)
+			errs = append(errs, fmt.Errorf("unable to delete DNS record %s: %w", id, err))
+		}
+	}
+
+	d.DNSRecordIDs = remaining
+
+	return errors.Join(errs...)
+}
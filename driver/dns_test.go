@@ -0,0 +1,44 @@
+package kubiqo
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func TestRenderHostname(t *testing.T) {
+	d := &Driver{
+		BaseDriver:       &drivers.BaseDriver{MachineName: "node-1"},
+		AvailabilityZone: "ch-gva-2",
+		APISecretKey:     "s3cr3t",
+	}
+
+	for text, want := range map[string]string{
+		"{{.MachineName}}.nodes.example.com":                 "node-1.nodes.example.com",
+		"{{.MachineName}}.{{.AvailabilityZone}}.example.com": "node-1.ch-gva-2.example.com",
+		"{{.MachineName}}.example.com.":                      "node-1.example.com",
+		"host.example.com":                                   "host.example.com",
+	} {
+		got, err := d.renderHostname(text)
+		if err != nil {
+			t.Errorf("renderHostname(%q) error = %v", text, err)
+		} else if got != want {
+			t.Errorf("renderHostname(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+// Templates only see the machine name and zone, not the rest of the config.
+func TestRenderHostnameRejectsOtherFields(t *testing.T) {
+	d := &Driver{BaseDriver: &drivers.BaseDriver{MachineName: "node-1"}, APISecretKey: "s3cr3t"}
+
+	for _, text := range []string{
+		"{{.APISecretKey}}.example.com",
+		"{{.SSHKeyPath}}.example.com",
+		"{{.MachineName.example.com",
+	} {
+		if got, err := d.renderHostname(text); err == nil {
+			t.Errorf("renderHostname(%q) = %q, want an error", text, got)
+		}
+	}
+}
@@ -12,13 +12,14 @@ import (
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
-	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	v3 "github.com/exoscale/egoscale/v3"
 	"github.com/exoscale/egoscale/v3/credentials"
@@ -26,22 +27,52 @@ import (
 
 type Driver struct {
 	*drivers.BaseDriver
-	URL              string
-	APIKey           string `json:"ApiKey"`
-	APISecretKey     string `json:"ApiSecretKey"`
-	InstanceProfile  string
-	DiskSize         int64
-	Image            string
-	SecurityGroups   []string
-	AffinityGroups   []string
-	AvailabilityZone string
-	SSHKey           string
-	KeyPair          string
-	Password         string
-	PublicKey        string
-	UserDataFile     string
-	UserData         []byte
-	ID               v3.UUID `json:"Id"`
+	URL                   string
+	APIKey                string `json:"ApiKey"`
+	APISecretKey          string `json:"ApiSecretKey"`
+	CredentialsCommand    string
+	CredentialsFile       string
+	SkipCredentialCheck   bool
+	InstanceProfile       string
+	DiskSize              int64
+	Image                 string
+	SecurityGroups        []string
+	AffinityGroups        []string
+	AvailabilityZone      string
+	SSHKey                string
+	SSHKeyType            string
+	KeyPair               string
+	SSHKeyPairNames       []string
+	AuthorizedKeys        []string
+	Password              string
+	PublicKey             string
+	UserDataFile          string
+	UserData              []byte
+	BaseUserData          []byte
+	Labels                map[string]string
+	BlockVolumes          []string
+	BlockVolumeFilesystem string
+	RetainBlockVolumes    bool
+	BlockVolumeIDs        []v3.UUID
+	SnapshotOnRemove      bool
+	FromSnapshot          string
+	SecureBoot            bool
+	TPM                   bool
+	DeployTarget          string
+	ReverseDNS            string
+	DNSDomain             string
+	DNSDomainID           v3.UUID
+	DNSRecordIDs          []v3.UUID
+	StopTimeout           int
+	StopForce             bool
+	InRescue              bool
+	WaitCloudInit         bool
+	CloudInitTimeout      int
+	SSHHostKey            string
+	ID                    v3.UUID `json:"Id"`
+
+	commandCredentials   *apiCredentials
+	commandCredentialsAt time.Time
 }
 
 const (
@@ -49,8 +80,13 @@ const (
 	defaultDiskSize         = 50
 	defaultImage            = "Linux Ubuntu 24.04 LTS 64-bit"
 	defaultAvailabilityZone = "ch-dk-2"
+	minDiskSize             = 10
+	maxDiskSize             = 51200
 	defaultSSHUser          = "root"
 	defaultSecurityGroup    = "rancher-machine"
+	defaultStopTimeout      = 300
+	killCommand             = "sudo poweroff -f"
+	killSSHTimeout          = 30 * time.Second
 	defaultCloudInit        = `#cloud-config
 manage_etc_hosts: localhost
 `
@@ -63,6 +99,8 @@ func NewDriver(machineName, storePath string) drivers.Driver {
 		DiskSize:         defaultDiskSize,
 		Image:            defaultImage,
 		AvailabilityZone: defaultAvailabilityZone,
+		StopTimeout:      defaultStopTimeout,
+		CloudInitTimeout: defaultCloudInitTimeout,
 		BaseDriver: &drivers.BaseDriver{
 			MachineName: machineName,
 			StorePath:   storePath,
@@ -89,6 +127,21 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "exoscale-api-secret-key",
 			Usage:  "exoscale API secret key",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_CREDENTIALS_COMMAND",
+			Name:   "exoscale-credentials-command",
+			Usage:  "command printing a JSON document with the API key and secret",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_CREDENTIALS_FILE",
+			Name:   "exoscale-credentials-file",
+			Usage:  "path to a JSON file with the API key and secret, re-read on every API call",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "EXOSCALE_SKIP_CREDENTIAL_CHECK",
+			Name:   "exoscale-skip-credential-check",
+			Usage:  "skip verifying the IAM permissions of the API key before creation",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "EXOSCALE_INSTANCE_PROFILE",
 			Name:   "exoscale-instance-profile",
@@ -99,7 +152,7 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			EnvVar: "EXOSCALE_DISK_SIZE",
 			Name:   "exoscale-disk-size",
 			Value:  defaultDiskSize,
-			Usage:  "exoscale disk size (10, 50, 100, 200, 400)",
+			Usage:  "exoscale disk size in GiB (10 to 51200)",
 		},
 		mcnflag.StringFlag{
 			EnvVar: "EXOSCALE_IMAGE",
@@ -131,6 +184,24 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  "",
 			Usage:  "path to the SSH user private key",
 		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_SSH_KEY_TYPE",
+			Name:   "exoscale-ssh-key-type",
+			Value:  sshKeyTypeRSA,
+			Usage:  "type of the generated SSH key (rsa, ed25519, ecdsa)",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "EXOSCALE_SSH_KEYPAIR_NAME",
+			Name:   "exoscale-ssh-keypair-name",
+			Value:  []string{},
+			Usage:  "name of an SSH key registered in the organization to attach to the instance",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "EXOSCALE_AUTHORIZED_KEY",
+			Name:   "exoscale-authorized-key",
+			Value:  []string{},
+			Usage:  "additional public key authorized for the SSH user (key, file or URL)",
+		},
 		mcnflag.StringFlag{
 			EnvVar: "EXOSCALE_USERDATA",
 			Name:   "exoscale-userdata",
@@ -142,6 +213,85 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  []string{},
 			Usage:  "exoscale affinity group",
 		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "EXOSCALE_LABEL",
+			Name:   "exoscale-label",
+			Value:  []string{},
+			Usage:  "label (key=value) applied to created resources",
+		},
+		mcnflag.StringSliceFlag{
+			EnvVar: "EXOSCALE_BLOCK_VOLUME",
+			Name:   "exoscale-block-volume",
+			Value:  []string{},
+			Usage:  "block storage volume (size[:name], size in GiB) attached to the instance",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_BLOCK_VOLUME_FILESYSTEM",
+			Name:   "exoscale-block-volume-filesystem",
+			Usage:  "filesystem (e.g. ext4, xfs) to format block volumes with, mounted under /mnt/<name>",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "EXOSCALE_BLOCK_VOLUME_RETAIN",
+			Name:   "exoscale-block-volume-retain",
+			Usage:  "keep block storage volumes when the machine is removed",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "EXOSCALE_SNAPSHOT_ON_REMOVE",
+			Name:   "exoscale-snapshot-on-remove",
+			Usage:  "take a snapshot of the root disk before the machine is removed",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_FROM_SNAPSHOT",
+			Name:   "exoscale-from-snapshot",
+			Usage:  "ID of an instance snapshot to create the machine from, instead of --exoscale-image",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "EXOSCALE_SECURE_BOOT",
+			Name:   "exoscale-secure-boot",
+			Usage:  "enable Secure Boot (requires a UEFI image)",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "EXOSCALE_TPM",
+			Name:   "exoscale-tpm",
+			Usage:  "enable a virtual Trusted Platform Module (requires a UEFI image)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_DEPLOY_TARGET",
+			Name:   "exoscale-deploy-target",
+			Usage:  "name or ID of the deploy target (dedicated hypervisor) to place the instance on",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_REVERSE_DNS",
+			Name:   "exoscale-reverse-dns",
+			Usage:  "reverse DNS of the instance public IPs, may be a template (e.g. {{.MachineName}}.nodes.example.com)",
+		},
+		mcnflag.StringFlag{
+			EnvVar: "EXOSCALE_DNS_DOMAIN",
+			Name:   "exoscale-dns-domain",
+			Usage:  "exoscale DNS domain in which to register A/AAAA records for the machine name",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "EXOSCALE_STOP_TIMEOUT",
+			Name:   "exoscale-stop-timeout",
+			Value:  defaultStopTimeout,
+			Usage:  "seconds to wait for a graceful stop (0 waits forever)",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "EXOSCALE_STOP_FORCE",
+			Name:   "exoscale-stop-force",
+			Usage:  "power the instance off when it does not stop within --exoscale-stop-timeout",
+		},
+		mcnflag.BoolFlag{
+			EnvVar: "EXOSCALE_WAIT_CLOUD_INIT",
+			Name:   "exoscale-wait-cloud-init",
+			Usage:  "wait for cloud-init to finish before completing creation",
+		},
+		mcnflag.IntFlag{
+			EnvVar: "EXOSCALE_CLOUD_INIT_TIMEOUT",
+			Name:   "exoscale-cloud-init-timeout",
+			Value:  defaultCloudInitTimeout,
+			Usage:  "seconds to wait for cloud-init to finish",
+		},
 	}
 }
 
@@ -225,6 +375,9 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.URL = flags.String("exoscale-url")
 	d.APIKey = flags.String("exoscale-api-key")
 	d.APISecretKey = flags.String("exoscale-api-secret-key")
+	d.CredentialsCommand = flags.String("exoscale-credentials-command")
+	d.CredentialsFile = flags.String("exoscale-credentials-file")
+	d.SkipCredentialCheck = flags.Bool("exoscale-skip-credential-check")
 	d.InstanceProfile = flags.String("exoscale-instance-profile")
 	d.DiskSize = int64(flags.Int("exoscale-disk-size"))
 	d.Image = flags.String("exoscale-image")
@@ -233,11 +386,53 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 	d.AvailabilityZone = flags.String("exoscale-availability-zone")
 	d.SSHUser = flags.String("exoscale-ssh-user")
 	d.SSHKey = flags.String("exoscale-ssh-key")
+	d.SSHKeyType = flags.String("exoscale-ssh-key-type")
+	if d.SSHKeyType == "" {
+		d.SSHKeyType = sshKeyTypeRSA
+	}
+	if err := validateSSHKeyType(d.SSHKeyType); err != nil {
+		return err
+	}
+	d.SSHKeyPairNames = flags.StringSlice("exoscale-ssh-keypair-name")
+	d.AuthorizedKeys = flags.StringSlice("exoscale-authorized-key")
 	d.UserDataFile = flags.String("exoscale-userdata")
 	d.UserData = []byte(defaultCloudInit)
 	d.SetSwarmConfigFromFlags(flags)
 
-	if d.APIKey == "" || d.APISecretKey == "" {
+	labels, err := parseLabels(flags.StringSlice("exoscale-label"))
+	if err != nil {
+		return err
+	}
+	d.Labels = labels
+
+	d.BlockVolumes = flags.StringSlice("exoscale-block-volume")
+	d.BlockVolumeFilesystem = flags.String("exoscale-block-volume-filesystem")
+	d.RetainBlockVolumes = flags.Bool("exoscale-block-volume-retain")
+	d.SnapshotOnRemove = flags.Bool("exoscale-snapshot-on-remove")
+	d.FromSnapshot = flags.String("exoscale-from-snapshot")
+	d.SecureBoot = flags.Bool("exoscale-secure-boot")
+	d.TPM = flags.Bool("exoscale-tpm")
+	d.DeployTarget = flags.String("exoscale-deploy-target")
+	d.ReverseDNS = flags.String("exoscale-reverse-dns")
+	d.DNSDomain = flags.String("exoscale-dns-domain")
+	d.StopTimeout = flags.Int("exoscale-stop-timeout")
+	d.StopForce = flags.Bool("exoscale-stop-force")
+	d.WaitCloudInit = flags.Bool("exoscale-wait-cloud-init")
+	d.CloudInitTimeout = flags.Int("exoscale-cloud-init-timeout")
+	if d.ReverseDNS != "" {
+		if _, err := d.renderHostname(d.ReverseDNS); err != nil {
+			return err
+		}
+	}
+	if _, err := parseBlockVolumes(d.MachineName, d.BlockVolumes); err != nil {
+		return err
+	}
+
+	if d.CredentialsCommand != "" && d.CredentialsFile != "" {
+		return errors.New("--exoscale-credentials-command and --exoscale-credentials-file are mutually exclusive")
+	}
+
+	if d.CredentialsCommand == "" && d.CredentialsFile == "" && (d.APIKey == "" || d.APISecretKey == "") {
 		return errors.New("missing an API key (--exoscale-api-key) or API secret key (--exoscale-api-secret-key)")
 	}
 
@@ -247,12 +442,68 @@ func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
 // PreCreateCheck allows for pre-create operations to make sure a driver is
 // ready for creation
 func (d *Driver) PreCreateCheck() error {
+	if d.CredentialsFile != "" {
+		if _, err := os.Stat(d.CredentialsFile); os.IsNotExist(err) {
+			return fmt.Errorf("credentials file %s could not be found", d.CredentialsFile)
+		}
+	}
+
 	if d.UserDataFile != "" {
 		if _, err := os.Stat(d.UserDataFile); os.IsNotExist(err) {
 			return fmt.Errorf("user-data file %s could not be found", d.UserDataFile)
 		}
 	}
 
+	if d.DiskSize < minDiskSize || d.DiskSize > maxDiskSize {
+		return fmt.Errorf("invalid disk size %d, it must be between %d and %d GiB", d.DiskSize, minDiskSize, maxDiskSize)
+	}
+
+	ctx := context.Background()
+	if !d.SkipCredentialCheck {
+		log.Infof("Checking exoscale API credentials...")
+		if err := d.CheckCredentials(ctx); err != nil {
+			return err
+		}
+	}
+
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	if d.FromSnapshot != "" {
+		if err := d.checkSnapshotSource(ctx, client); err != nil {
+			return err
+		}
+	} else {
+		template, err := d.findTemplate(ctx, client, d.Image)
+		if err != nil {
+			return err
+		}
+
+		// findTemplate only resolves 10 GiB templates, which minDiskSize
+		// already covers.
+		if err := d.checkSecurityFeatures(template); err != nil {
+			return err
+		}
+	}
+
+	if _, err := d.findInstanceType(ctx, client, d.InstanceProfile); err != nil {
+		return err
+	}
+
+	if _, err := d.findDeployTarget(ctx, client); err != nil {
+		return err
+	}
+
+	if _, err := d.findSSHKeyPairs(ctx, client); err != nil {
+		return err
+	}
+
+	if _, err := d.resolveAuthorizedKeys(ctx); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -271,8 +522,14 @@ func (d *Driver) GetURL() (string, error) {
 	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, "2376")), nil
 }
 
-func (d *Driver) client(ctx context.Context) (*v3.Client, error) {
-	client, err := v3.NewClient(credentials.NewStaticCredentials(d.APIKey, d.APISecretKey))
+// apiClient returns a client bound to the global API endpoint.
+func (d *Driver) apiClient(ctx context.Context) (*v3.Client, error) {
+	apiKey, apiSecret, err := d.credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := v3.NewClient(credentials.NewStaticCredentials(apiKey, apiSecret))
 	if err != nil {
 		return nil, err
 	}
@@ -281,6 +538,16 @@ func (d *Driver) client(ctx context.Context) (*v3.Client, error) {
 		client = client.WithEndpoint(v3.Endpoint(d.URL))
 	}
 
+	return client, nil
+}
+
+// client returns a client bound to the endpoint of the availability zone.
+func (d *Driver) client(ctx context.Context) (*v3.Client, error) {
+	client, err := d.apiClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	zones, err := client.ListZones(ctx)
 	if err != nil {
 		return nil, err
@@ -297,42 +564,79 @@ func (d *Driver) client(ctx context.Context) (*v3.Client, error) {
 	return client, nil
 }
 
+// InstanceNotFoundError is returned when the instance of the machine does
+// not exist anymore, typically because it was deleted out-of-band.
+type InstanceNotFoundError struct {
+	ID v3.UUID
+}
+
+func (e *InstanceNotFoundError) Error() string {
+	return fmt.Sprintf("instance %s not found", e.ID)
+}
+
+// Unwrap makes errors.Is(err, v3.ErrNotFound) hold for InstanceNotFoundError.
+func (e *InstanceNotFoundError) Unwrap() error {
+	return v3.ErrNotFound
+}
+
 func (d *Driver) getInstance() (*v3.Instance, error) {
+	if d.ID == "" {
+		return nil, &InstanceNotFoundError{}
+	}
+
 	ctx := context.Background()
 	client, err := d.client(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	return client.GetInstance(ctx, d.ID)
+	instance, err := client.GetInstance(ctx, d.ID)
+	if errors.Is(err, v3.ErrNotFound) {
+		return nil, &InstanceNotFoundError{ID: d.ID}
+	}
+
+	return instance, err
 }
 
 // GetState returns a github.com/machine/libmachine/state.State representing the state of the host (running, stopped, etc.)
 func (d *Driver) GetState() (state.State, error) {
 	instance, err := d.getInstance()
 	if err != nil {
+		var notFound *InstanceNotFoundError
+		if errors.As(err, &notFound) {
+			return state.None, err
+		}
 		return state.Error, err
 	}
-	switch instance.State {
+
+	return d.instanceState(instance.State)
+}
+
+// instanceState maps the API state of the instance to a machine state.
+func (d *Driver) instanceState(st v3.InstanceState) (state.State, error) {
+	switch st {
 	case v3.InstanceStateStarting:
 		return state.Starting, nil
 	case v3.InstanceStateRunning:
+		if d.InRescue {
+			// The instance runs the rescue system, not its own.
+			return state.Paused, nil
+		}
+		return state.Running, nil
+	case v3.InstanceStateMigrating:
+		// Live migration keeps the instance running.
 		return state.Running, nil
-	case v3.InstanceStateStopping:
+	case v3.InstanceStateStopping, v3.InstanceStateDestroying:
 		return state.Stopping, nil
 	case v3.InstanceStateStopped:
 		return state.Stopped, nil
-	case v3.InstanceStateDestroying:
-		return state.Stopped, nil
-	case v3.InstanceStateDestroyed:
-		return state.Stopped, nil
-	case v3.InstanceStateExpunging:
-		return state.Stopped, nil
-	case v3.InstanceStateMigrating:
-		return state.Paused, nil
+	case v3.InstanceStateDestroyed, v3.InstanceStateExpunging:
+		return state.None, &InstanceNotFoundError{ID: d.ID}
 	case v3.InstanceStateError:
 		return state.Error, nil
 	}
+
+	log.Warnf("Instance %s is in unknown state %q", d.ID, st)
 	return state.None, nil
 }
 
@@ -344,7 +648,7 @@ func (d *Driver) createDefaultSecurityGroup(ctx context.Context, sgName string)
 
 	op, err := client.CreateSecurityGroup(ctx, v3.CreateSecurityGroupRequest{
 		Name:        sgName,
-		Description: "created by rancher-machine",
+		Description: sharedGroupDescription,
 	})
 	if err != nil {
 		return "", err
@@ -544,7 +848,7 @@ func (d *Driver) createDefaultAffinityGroup(ctx context.Context, agName string)
 
 	resp, err := client.CreateAntiAffinityGroup(ctx, v3.CreateAntiAffinityGroupRequest{
 		Name:        agName,
-		Description: "created by rancher-machine",
+		Description: sharedGroupDescription,
 	})
 	if err != nil {
 		return "", err
@@ -558,29 +862,15 @@ func (d *Driver) createDefaultAffinityGroup(ctx context.Context, agName string)
 	return op.Reference.ID, nil
 }
 
-// Create creates the Instance acting as the docker host
-func (d *Driver) Create() error {
-	cloudInit, err := d.getCloudInit()
-	if err != nil {
-		return err
-	}
-
-	ctx := context.Background()
-	log.Infof("Querying exoscale for the requested parameters...")
-	client, err := d.client(ctx)
-	if err != nil {
-		return err
-	}
-
-	// Image
+// findTemplate resolves image, either by its full name or by its short name
+// (e.g. ubuntu-24.04), among the 10GiB templates.
+func (d *Driver) findTemplate(ctx context.Context, client *v3.Client, image string) (v3.Template, error) {
 	templates, err := client.ListTemplates(ctx)
 	if err != nil {
-		return err
+		return v3.Template{}, err
 	}
 
-	template := v3.Template{}
-
-	image := strings.ToLower(d.Image)
+	want := strings.ToLower(image)
 	re := regexp.MustCompile(`^Linux (?P<name>.+?) (?P<version>[0-9.]+)\b`)
 
 	for _, tpl := range templates.Templates {
@@ -590,9 +880,8 @@ func (d *Driver) Create() error {
 		}
 
 		fullname := strings.ToLower(tpl.Name)
-		if image == fullname {
-			template = tpl
-			break
+		if want == fullname {
+			return tpl, nil
 		}
 
 		submatch := re.FindStringSubmatch(tpl.Name)
@@ -601,14 +890,100 @@ func (d *Driver) Create() error {
 			version := submatch[2]
 			shortname := fmt.Sprintf("%s-%s", name, version)
 
-			if image == shortname {
-				template = tpl
-				break
+			if want == shortname {
+				return tpl, nil
 			}
 		}
 	}
-	if template.ID == "" {
-		return fmt.Errorf("unable to find image %v", d.Image)
+
+	return v3.Template{}, fmt.Errorf("unable to find image %v", image)
+}
+
+// checkSecurityFeatures verifies that template supports the requested
+// Secure Boot and TPM options.
+func (d *Driver) checkSecurityFeatures(template v3.Template) error {
+	if (d.SecureBoot || d.TPM) && template.BootMode != v3.TemplateBootModeUefi {
+		return fmt.Errorf("image %s does not boot in UEFI mode, Secure Boot and TPM are not supported", template.Name)
+	}
+
+	return nil
+}
+
+// findInstanceType resolves profile to an instance type available in the
+// availability zone.
+func (d *Driver) findInstanceType(ctx context.Context, client *v3.Client, profile string) (v3.InstanceType, error) {
+	instTypes, err := client.ListInstanceTypes(ctx)
+	if err != nil {
+		return v3.InstanceType{}, err
+	}
+
+	instType, err := instTypes.FindInstanceTypeByIdOrFamilyAndSize(profile)
+	if err != nil {
+		return v3.InstanceType{}, err
+	}
+
+	if len(instType.Zones) > 0 && !slices.Contains(instType.Zones, v3.ZoneName(d.AvailabilityZone)) {
+		return v3.InstanceType{}, fmt.Errorf("instance profile %s is not available in zone %s", profile, d.AvailabilityZone)
+	}
+
+	return instType, nil
+}
+
+// findDeployTarget resolves d.DeployTarget among the deploy targets of the
+// availability zone, it returns nil when no deploy target is requested.
+func (d *Driver) findDeployTarget(ctx context.Context, client *v3.Client) (*v3.DeployTarget, error) {
+	if d.DeployTarget == "" {
+		return nil, nil
+	}
+
+	targets, err := client.ListDeployTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := targets.FindDeployTarget(d.DeployTarget)
+	if errors.Is(err, v3.ErrNotFound) {
+		return nil, fmt.Errorf("deploy target %s not found in zone %s", d.DeployTarget, d.AvailabilityZone)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &target, nil
+}
+
+// Create creates the Instance acting as the docker host
+func (d *Driver) Create() error {
+	cloudInit, err := d.getCloudInit()
+	if err != nil {
+		return err
+	}
+
+	cloudInit, err = d.appendBlockVolumesCloudInit(cloudInit)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	log.Infof("Querying exoscale for the requested parameters...")
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Image
+	var template v3.Template
+	if d.FromSnapshot != "" {
+		template, err = d.snapshotTemplate(ctx, client)
+	} else {
+		template, err = d.findTemplate(ctx, client, d.Image)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := d.checkSecurityFeatures(template); err != nil {
+		return err
 	}
 
 	// Reading the username from the template
@@ -618,18 +993,19 @@ func (d *Driver) Create() error {
 	log.Debugf("Image %v(10) = %s (%s)", d.Image, template.ID, d.SSHUser)
 
 	// Profile UUID
-	instTypes, err := client.ListInstanceTypes(ctx)
+	instType, err := d.findInstanceType(ctx, client, d.InstanceProfile)
 	if err != nil {
 		return err
 	}
 
-	instType, err := instTypes.FindInstanceTypeByIdOrFamilyAndSize(d.InstanceProfile)
+	log.Debugf("Profile %v = %v", d.InstanceProfile, instType)
+
+	// Deploy target
+	deployTarget, err := d.findDeployTarget(ctx, client)
 	if err != nil {
 		return err
 	}
 
-	log.Debugf("Profile %v = %v", d.InstanceProfile, instType)
-
 	// Security groups
 	sgs := make([]v3.SecurityGroup, 0, len(d.SecurityGroups))
 	for _, sgName := range d.SecurityGroups {
@@ -701,18 +1077,22 @@ func (d *Driver) Create() error {
 		})
 	}
 
+	authorizedKeys, err := d.resolveAuthorizedKeys(ctx)
+	if err != nil {
+		return err
+	}
+
 	// SSH key pair
 	if d.SSHKey == "" {
 		keyPairName := fmt.Sprintf("rancher-machine-%s", d.MachineName)
 		log.Infof("Generate an SSH keypair...")
 
-		err = ssh.GenerateSSHKey(d.GetSSHKeyPath())
+		keyPath, err := d.generateSSHKey()
 		if err != nil {
 			return err
 		}
 
-		pubKeyPath := d.ResolveStorePath("id_rsa.pub")
-		pubKey, err := os.ReadFile(pubKeyPath)
+		pubKey, err := os.ReadFile(keyPath + ".pub")
 		if err != nil {
 			return err
 		}
@@ -734,15 +1114,9 @@ func (d *Driver) Create() error {
 	} else {
 		log.Infof("Importing SSH key from %s", d.SSHKey)
 
-		sshKey := d.SSHKey
-		if strings.HasPrefix(sshKey, "~/") {
-			usr, _ := user.Current()
-			sshKey = filepath.Join(usr.HomeDir, sshKey[2:])
-		} else {
-			var errA error
-			if sshKey, errA = filepath.Abs(sshKey); errA != nil {
-				return errA
-			}
+		sshKey, errA := d.importedSSHKeyPath()
+		if errA != nil {
+			return errA
 		}
 
 		// Sending the SSH public key through the cloud-init config
@@ -751,10 +1125,7 @@ func (d *Driver) Create() error {
 			return fmt.Errorf("cannot read SSH public key %s", errR)
 		}
 
-		sshAuthorizedKeys := `
-ssh_authorized_keys:
-- `
-		cloudInit = bytes.Join([][]byte{cloudInit, []byte(sshAuthorizedKeys), pubKey}, []byte(""))
+		authorizedKeys = append(authorizedKeys, pubKey)
 
 		// Copying the private key into rancher-machine
 		if errCopy := mcnutils.CopyFile(sshKey, d.GetSSHKeyPath()); errCopy != nil {
@@ -765,11 +1136,25 @@ ssh_authorized_keys:
 		}
 	}
 
-	sshKey, err := client.GetSSHKey(ctx, d.KeyPair)
+	cloudInit, err = appendAuthorizedKeys(cloudInit, authorizedKeys...)
 	if err != nil {
 		return err
 	}
 
+	// Registered keys are attached alongside the driver key, which is only
+	// registered when generated.
+	sshKeys, err := d.findSSHKeyPairs(ctx, client)
+	if err != nil {
+		return err
+	}
+	if d.KeyPair != "" {
+		sshKey, err := client.GetSSHKey(ctx, d.KeyPair)
+		if err != nil {
+			return err
+		}
+		sshKeys = append(sshKeys, *sshKey)
+	}
+
 	log.Infof("Spawn exoscale host...")
 	log.Debugf("Using the following cloud-init file:")
 	log.Debugf("%s", string(cloudInit))
@@ -778,14 +1163,25 @@ ssh_authorized_keys:
 	d.UserData = cloudInit
 	encodedUserData := base64.StdEncoding.EncodeToString(d.UserData)
 
+	// Block volumes must be attached before the first boot for cloud-init
+	// to format and mount them.
+	if err := d.createBlockVolumes(ctx, client); err != nil {
+		return err
+	}
+
 	op, err := client.CreateInstance(ctx, v3.CreateInstanceRequest{
+		AutoStart:          v3.Bool(len(d.BlockVolumeIDs) == 0),
 		Template:           &template,
 		Ipv6Enabled:        v3.Bool(true),
 		DiskSize:           d.DiskSize,
 		InstanceType:       &instType,
 		UserData:           encodedUserData,
 		Name:               d.MachineName,
-		SSHKeys:            []v3.SSHKey{*sshKey},
+		Labels:             d.resourceLabels(),
+		SecurebootEnabled:  v3.Bool(d.SecureBoot),
+		TpmEnabled:         v3.Bool(d.TPM),
+		DeployTarget:       deployTarget,
+		SSHKeys:            sshKeys,
 		SecurityGroups:     sgs,
 		AntiAffinityGroups: ags,
 	})
@@ -812,6 +1208,33 @@ ssh_authorized_keys:
 	d.ID = instance.ID
 	log.Infof("IP Address: %v, SSH User: %v", d.IPAddress, d.GetSSHUsername())
 
+	if len(d.BlockVolumeIDs) > 0 {
+		if err := d.attachBlockVolumes(ctx, client); err != nil {
+			return err
+		}
+
+		op, err := client.StartInstance(ctx, d.ID, v3.StartInstanceRequest{})
+		if err != nil {
+			return err
+		}
+
+		if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+			return err
+		}
+	}
+
+	if d.ReverseDNS != "" {
+		if err := d.setReverseDNS(ctx, client); err != nil {
+			return err
+		}
+	}
+
+	if d.DNSDomain != "" {
+		if err := d.createDNSRecords(ctx, client, instance); err != nil {
+			return err
+		}
+	}
+
 	if instance.Template != nil && instance.Template.PasswordEnabled != nil && *instance.Template.PasswordEnabled {
 		res, err := client.RevealInstancePassword(ctx, instance.ID)
 		if err != nil {
@@ -821,12 +1244,12 @@ ssh_authorized_keys:
 		d.Password = res.Password
 	}
 
+	if err := d.waitForSSHReady(); err != nil {
+		return err
+	}
+
 	// Destroy the SSH key
 	if d.KeyPair != "" {
-		if err := drivers.WaitForSSH(d); err != nil {
-			return err
-		}
-
 		op, err := client.DeleteSSHKey(ctx, d.KeyPair)
 		if err != nil {
 			return err
@@ -840,11 +1263,55 @@ ssh_authorized_keys:
 		d.KeyPair = ""
 	}
 
+	if d.WaitCloudInit {
+		if err := d.waitForCloudInit(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// Start starts the existing Instance.
+// waitForSettledState waits out the transitional Starting and Stopping
+// states and returns the state the instance settled in.
+func (d *Driver) waitForSettledState() (state.State, error) {
+	var st state.State
+	settled := func() (bool, error) {
+		var err error
+		st, err = d.GetState()
+		if err != nil {
+			return false, err
+		}
+
+		if st == state.Starting || st == state.Stopping {
+			log.Debugf("Instance %s is %s, waiting...", d.ID, st)
+			return false, nil
+		}
+		return true, nil
+	}
+
+	if err := mcnutils.WaitForSpecificOrError(settled, 60, 5*time.Second); err != nil {
+		return st, err
+	}
+
+	return st, nil
+}
+
+// Start starts the existing Instance and waits for SSH.
 func (d *Driver) Start() error {
+	st, err := d.waitForSettledState()
+	if err != nil {
+		return err
+	}
+
+	if st == state.Running {
+		log.Debugf("Instance %s is already running", d.ID)
+		return nil
+	}
+	if st == state.Paused && d.InRescue {
+		return fmt.Errorf("instance %s is running in rescue mode, run the rescue exit command to start it normally", d.ID)
+	}
+
 	ctx := context.Background()
 	client, err := d.client(ctx)
 	if err != nil {
@@ -856,13 +1323,26 @@ func (d *Driver) Start() error {
 		return err
 	}
 
-	_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
 
-	return err
+	return d.waitForSSHReady()
 }
 
-// Stop stops the existing Instance.
+// Stop stops the existing Instance, escalating to Kill with StopForce.
 func (d *Driver) Stop() error {
+	st, err := d.waitForSettledState()
+	if err != nil {
+		return err
+	}
+
+	if st == state.Stopped {
+		log.Debugf("Instance %s is already stopped", d.ID)
+		d.InRescue = false
+		return nil
+	}
+
 	ctx := context.Background()
 	client, err := d.client(ctx)
 	if err != nil {
@@ -874,13 +1354,47 @@ func (d *Driver) Stop() error {
 		return err
 	}
 
-	_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
+	waitCtx := ctx
+	if d.StopTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, time.Duration(d.StopTimeout)*time.Second)
+		defer cancel()
+	}
 
-	return err
+	_, err = client.Wait(waitCtx, op, v3.OperationStateSuccess)
+	if errors.Is(err, context.DeadlineExceeded) {
+		if !d.StopForce {
+			return fmt.Errorf("instance %s did not stop within %ds", d.ID, d.StopTimeout)
+		}
+
+		log.Warnf("Instance %s did not stop within %ds, powering it off", d.ID, d.StopTimeout)
+		return d.kill(true)
+	}
+	if err != nil {
+		return err
+	}
+
+	d.InRescue = false
+
+	return nil
 }
 
-// Restart reboots the existing Instance.
+// Restart reboots the existing Instance and waits for SSH.
 func (d *Driver) Restart() error {
+	st, err := d.waitForSettledState()
+	if err != nil {
+		return err
+	}
+
+	if st == state.Stopped {
+		return d.Start()
+	}
+
+	// A reboot may keep the rescue profile, stop and start to leave it.
+	if d.InRescue {
+		return d.ExitRescue()
+	}
+
 	ctx := context.Background()
 	client, err := d.client(ctx)
 	if err != nil {
@@ -891,14 +1405,86 @@ func (d *Driver) Restart() error {
 	if err != nil {
 		return err
 	}
-	_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
 
-	return err
+	return d.waitForSSHReady()
 }
 
-// Kill stops a host forcefully (same as Stop)
+// Kill stops a host forcefully, powering it off from the guest over SSH.
 func (d *Driver) Kill() error {
-	return d.Stop()
+	return d.kill(false)
+}
+
+// kill implements Kill, sending no API stop when stopPending.
+func (d *Driver) kill(stopPending bool) error {
+	st, err := d.GetState()
+	if err != nil {
+		return err
+	}
+	if st == state.Stopped {
+		log.Debugf("Instance %s is already stopped", d.ID)
+		d.InRescue = false
+		return nil
+	}
+
+	timeout := time.Duration(d.StopTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = defaultStopTimeout * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Infof("Powering off %s...", d.MachineName)
+	sshCtx, sshCancel := context.WithTimeout(ctx, killSSHTimeout)
+	err = d.runSSHCommand(sshCtx, killCommand)
+	sshCancel()
+	if err != nil {
+		// The connection is expected to drop when the power-off succeeds.
+		log.Debugf("Power-off over SSH: %s", err)
+	}
+
+	if !d.waitForStopped(ctx, killSSHTimeout) && !stopPending && st != state.Stopping {
+		log.Infof("%s is still running, stopping it through the API...", d.MachineName)
+
+		client, err := d.client(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := client.StopInstance(ctx, d.ID); err != nil {
+			return err
+		}
+	}
+
+	if !d.waitForStopped(ctx, timeout) {
+		return fmt.Errorf("instance %s did not power off within %s, the Exoscale API has no forced stop", d.ID, timeout)
+	}
+
+	d.InRescue = false
+
+	return nil
+}
+
+// waitForStopped reports whether the instance stopped within wait.
+func (d *Driver) waitForStopped(ctx context.Context, wait time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		if st, err := d.GetState(); err == nil && st == state.Stopped {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
 }
 
 // Remove destroys the Instance and the associated SSH key.
@@ -909,45 +1495,138 @@ func (d *Driver) Remove() error {
 		return err
 	}
 
+	var errs []error
+
 	// Destroy the SSH key
 	if d.KeyPair != "" {
-		op, err := client.DeleteSSHKey(ctx, d.KeyPair)
+		err := deleteResource(ctx, client, func() (*v3.Operation, error) {
+			return client.DeleteSSHKey(ctx, d.KeyPair)
+		})
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("unable to delete SSH key %s: %w", d.KeyPair, err))
+		} else {
+			d.KeyPair = ""
 		}
+	}
 
-		_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
-		if err != nil {
-			return err
-		}
+	// Destroy the DNS records
+	if err := d.deleteDNSRecords(ctx, client); err != nil {
+		errs = append(errs, err)
 	}
 
 	// Destroy the Instance
 	if d.ID != "" {
-		op, err := client.DeleteInstance(ctx, d.ID)
-		if err != nil {
-			return err
+		if d.ReverseDNS != "" {
+			if err := ignoreNotFound(d.clearReverseDNS(ctx, client)); err != nil {
+				errs = append(errs, fmt.Errorf("unable to clear reverse DNS: %w", err))
+			}
 		}
 
-		_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
-		if err != nil {
-			return err
+		if err := d.removeInstance(ctx, client); err != nil {
+			// Keep the data volumes along with the root disk.
+			errs = append(errs, err)
+			return errors.Join(errs...)
 		}
 	}
 
+	// Detach and destroy the block volumes
+	if err := d.removeBlockVolumes(ctx, client); err != nil {
+		errs = append(errs, err)
+	}
+
 	//TODO: cleanup Anti-Affinities and Security-Groups, not urgent for now.
 	log.Infof("The Anti-Affinity group and Security group were not removed")
 
+	return errors.Join(errs...)
+}
+
+// removeInstance deletes the instance, it returns an error whenever the
+// instance was kept.
+func (d *Driver) removeInstance(ctx context.Context, client *v3.Client) error {
+	if d.SnapshotOnRemove {
+		// Snapshots are deleted along with their instance, only a template
+		// promoted from the snapshot survives.
+		snapshot, err := d.createSnapshot(ctx, client)
+		if err == nil {
+			var template *v3.Template
+			template, err = promoteSnapshot(ctx, client, snapshot)
+			if err == nil {
+				log.Infof("Template %s (%s) of %s was kept", template.ID, template.Name, d.MachineName)
+			}
+		}
+		switch {
+		case errors.Is(err, v3.ErrNotFound):
+			log.Infof("Instance %s not found, no snapshot taken", d.ID)
+		case err != nil:
+			// Keep the instance rather than losing its data.
+			return fmt.Errorf("unable to snapshot instance %s, it was not deleted: %w", d.ID, err)
+		}
+	}
+
+	err := deleteResource(ctx, client, func() (*v3.Operation, error) {
+		return client.DeleteInstance(ctx, d.ID)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete instance %s: %w", d.ID, err)
+	}
+
 	return nil
 }
 
+// deleteResource runs a delete call and waits for its operation. A resource
+// that does not exist is considered deleted.
+func deleteResource(ctx context.Context, client *v3.Client, del func() (*v3.Operation, error)) error {
+	op, err := del()
+	if err != nil {
+		return ignoreNotFound(err)
+	}
+
+	_, err = client.Wait(ctx, op, v3.OperationStateSuccess)
+	return ignoreNotFound(err)
+}
+
+// ignoreNotFound returns nil for NotFound API errors.
+func ignoreNotFound(err error) error {
+	if errors.Is(err, v3.ErrNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// importedSSHKeyPath returns the absolute path of d.SSHKey, expanding ~/.
+func (d *Driver) importedSSHKeyPath() (string, error) {
+	if strings.HasPrefix(d.SSHKey, "~/") {
+		usr, _ := user.Current()
+		return filepath.Join(usr.HomeDir, d.SSHKey[2:]), nil
+	}
+
+	return filepath.Abs(d.SSHKey)
+}
+
 // Build a cloud-init user data string that will install and run
-// docker.
+// docker, from a copy of BaseUserData.
 func (d *Driver) getCloudInit() ([]byte, error) {
-	var err error
-	if d.UserDataFile != "" {
-		d.UserData, err = os.ReadFile(d.UserDataFile)
+	if d.BaseUserData == nil {
+		// Configs saved before BaseUserData existed only record where the
+		// base came from, rebuild it from there.
+		if d.ID != "" {
+			log.Infof("No base user data saved for %s, rebuilding it from the creation settings", d.MachineName)
+		}
+
+		d.BaseUserData = []byte(defaultCloudInit)
+		if d.UserDataFile != "" {
+			data, err := os.ReadFile(d.UserDataFile)
+			if err != nil {
+				if d.ID != "" {
+					return nil, fmt.Errorf("the config of %s predates saving the base user data and %s cannot be read: %w", d.MachineName, d.UserDataFile, err)
+				}
+				return nil, err
+			}
+			d.BaseUserData = data
+		}
 	}
 
-	return d.UserData, err
+	// Callers append to the returned user data.
+	return bytes.Clone(d.BaseUserData), nil
 }
@@ -0,0 +1,214 @@
+package kubiqo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+const keyPairPrefix = "rancher-machine-"
+
+// Orphan is a driver-owned resource that is not used by any live instance.
+type Orphan struct {
+	Zone string
+	Kind string
+	ID   string
+	Name string
+
+	client *v3.Client
+	delete func(ctx context.Context, client *v3.Client) (*v3.Operation, error)
+}
+
+func (o Orphan) String() string {
+	return fmt.Sprintf("%s\t%s\t%s\t%s", o.Zone, o.Kind, o.ID, o.Name)
+}
+
+// Delete removes the orphaned resource and waits for the operation.
+func (o Orphan) Delete(ctx context.Context) error {
+	op, err := o.delete(ctx, o.client)
+	if err != nil {
+		return err
+	}
+
+	_, err = o.client.Wait(ctx, op, v3.OperationStateSuccess)
+	return err
+}
+
+// zoneClients returns a client per zone, restricted to zone when not empty.
+func (d *Driver) zoneClients(ctx context.Context, zone string) (map[string]*v3.Client, error) {
+	client, err := d.apiClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := client.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clients := make(map[string]*v3.Client)
+	for _, z := range zones.Zones {
+		if zone != "" && string(z.Name) != zone {
+			continue
+		}
+		clients[string(z.Name)] = client.WithEndpoint(z.APIEndpoint)
+	}
+	if len(clients) == 0 {
+		return nil, fmt.Errorf("unknown zone %q", zone)
+	}
+
+	return clients, nil
+}
+
+// FindOrphans lists the driver-owned resources of zone (or all zones when
+// empty) that are not referenced by any instance. Ownership is established
+// by the rancher-machine- SSH key prefix and the group description.
+// Instances cannot be told apart from live machines managed elsewhere, so
+// only the instances listed in instanceIDs are reported, and only when they
+// carry the managed-by label.
+func (d *Driver) FindOrphans(ctx context.Context, zone string, instanceIDs []string) ([]Orphan, error) {
+	wanted := make(map[string]bool, len(instanceIDs))
+	for _, id := range instanceIDs {
+		wanted[id] = true
+	}
+
+	// References are collected across every zone since SSH keys and
+	// security groups are shared by the whole organization.
+	allClients, err := d.zoneClients(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	usedKeys := make(map[string]bool)
+	usedSGs := make(map[v3.UUID]bool)
+	var instances []Orphan
+	for z, client := range allClients {
+		list, err := client.ListInstances(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, instance := range list.Instances {
+			if instance.SSHKey != nil {
+				usedKeys[instance.SSHKey.Name] = true
+			}
+			for _, key := range instance.SSHKeys {
+				usedKeys[key.Name] = true
+			}
+			for _, sg := range instance.SecurityGroups {
+				usedSGs[sg.ID] = true
+			}
+
+			if !wanted[string(instance.ID)] || (zone != "" && z != zone) {
+				continue
+			}
+			if instance.Labels[labelManagedBy] != managedByValue {
+				return nil, fmt.Errorf("instance %s (%s) is not managed by the driver", instance.ID, instance.Name)
+			}
+			delete(wanted, string(instance.ID))
+			instances = append(instances, Orphan{
+				Zone:   z,
+				Kind:   "instance",
+				ID:     string(instance.ID),
+				Name:   instance.Name,
+				client: client,
+				delete: func(ctx context.Context, c *v3.Client) (*v3.Operation, error) {
+					return c.DeleteInstance(ctx, instance.ID)
+				},
+			})
+		}
+	}
+
+	for id := range wanted {
+		return nil, fmt.Errorf("instance %s not found", id)
+	}
+
+	clients, err := d.zoneClients(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := instances
+	seen := make(map[string]bool)
+	for z, client := range clients {
+		keys, err := client.ListSSHKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys.SSHKeys {
+			if !strings.HasPrefix(key.Name, keyPairPrefix) || usedKeys[key.Name] || seen[key.Name] {
+				continue
+			}
+			seen[key.Name] = true
+			orphans = append(orphans, Orphan{
+				Zone:   z,
+				Kind:   "ssh-key",
+				ID:     key.Fingerprint,
+				Name:   key.Name,
+				client: client,
+				delete: func(ctx context.Context, c *v3.Client) (*v3.Operation, error) {
+					return c.DeleteSSHKey(ctx, key.Name)
+				},
+			})
+		}
+
+		ags, err := client.ListAntiAffinityGroups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, ag := range ags.AntiAffinityGroups {
+			if !strings.HasPrefix(ag.Description, defaultDescription) || seen[string(ag.ID)] {
+				continue
+			}
+
+			// The listing does not include members, fetch them.
+			group, err := client.GetAntiAffinityGroup(ctx, ag.ID)
+			if err != nil {
+				return nil, err
+			}
+			if len(group.Instances) > 0 {
+				continue
+			}
+
+			seen[string(ag.ID)] = true
+			orphans = append(orphans, Orphan{
+				Zone:   z,
+				Kind:   "anti-affinity-group",
+				ID:     string(ag.ID),
+				Name:   ag.Name,
+				client: client,
+				delete: func(ctx context.Context, c *v3.Client) (*v3.Operation, error) {
+					return c.DeleteAntiAffinityGroup(ctx, ag.ID)
+				},
+			})
+		}
+
+		sgs, err := client.ListSecurityGroups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, sg := range sgs.SecurityGroups {
+			if !strings.HasPrefix(sg.Description, defaultDescription) || usedSGs[sg.ID] || seen[string(sg.ID)] {
+				continue
+			}
+			seen[string(sg.ID)] = true
+			orphans = append(orphans, Orphan{
+				Zone:   z,
+				Kind:   "security-group",
+				ID:     string(sg.ID),
+				Name:   sg.Name,
+				client: client,
+				delete: func(ctx context.Context, c *v3.Client) (*v3.Operation, error) {
+					return c.DeleteSecurityGroup(ctx, sg.ID)
+				},
+			})
+		}
+	}
+
+	log.Debugf("Found %d orphaned resources", len(orphans))
+
+	return orphans, nil
+}
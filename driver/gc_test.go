@@ -0,0 +1,120 @@
+package kubiqo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	managedInstanceID   = "11111111-1111-1111-1111-111111111111"
+	unmanagedInstanceID = "22222222-2222-2222-2222-222222222222"
+	usedGroupID         = "33333333-3333-3333-3333-333333333333"
+	orphanGroupID       = "44444444-4444-4444-4444-444444444444"
+	foreignGroupID      = "55555555-5555-5555-5555-555555555555"
+	emptyAffinityID     = "66666666-6666-6666-6666-666666666666"
+	usedAffinityID      = "77777777-7777-7777-7777-777777777777"
+)
+
+// newGCTestDriver returns a driver talking to a fake API with a single zone.
+func newGCTestDriver(t *testing.T) *Driver {
+	t.Helper()
+
+	var srv *httptest.Server
+	responses := map[string]string{
+		"/instance": `{"instances": [
+			{"id": "` + managedInstanceID + `", "name": "stray", "labels": {"managed-by": "kubiqo"},
+			 "ssh-key": {"name": "rancher-machine-used"}, "security-groups": [{"id": "` + usedGroupID + `"}]},
+			{"id": "` + unmanagedInstanceID + `", "name": "rancher-node"}
+		]}`,
+		"/ssh-key": `{"ssh-keys": [
+			{"name": "rancher-machine-used", "fingerprint": "aa"},
+			{"name": "rancher-machine-orphan", "fingerprint": "bb"},
+			{"name": "admin", "fingerprint": "cc"}
+		]}`,
+		"/security-group": `{"security-groups": [
+			{"id": "` + usedGroupID + `", "name": "rancher-machine", "description": "created by rancher-machine (managed-by=kubiqo)"},
+			{"id": "` + orphanGroupID + `", "name": "old", "description": "created by rancher-machine"},
+			{"id": "` + foreignGroupID + `", "name": "web", "description": "managed by hand"}
+		]}`,
+		"/anti-affinity-group": `{"anti-affinity-groups": [
+			{"id": "` + emptyAffinityID + `", "name": "empty", "description": "created by rancher-machine"},
+			{"id": "` + usedAffinityID + `", "name": "used", "description": "created by rancher-machine"}
+		]}`,
+		"/anti-affinity-group/" + emptyAffinityID: `{"id": "` + emptyAffinityID + `"}`,
+		"/anti-affinity-group/" + usedAffinityID:  `{"id": "` + usedAffinityID + `", "instances": [{"id": "` + managedInstanceID + `"}]}`,
+	}
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("unexpected %s %s, FindOrphans must not modify anything", r.Method, r.URL.Path)
+			http.Error(w, "read-only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/zone" {
+			fmt.Fprintf(w, `{"zones": [{"name": "ch-gva-2", "api-endpoint": %q}]}`, srv.URL)
+			return
+		}
+
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	return &Driver{URL: srv.URL, APIKey: "EXOtest", APISecretKey: "secret"}
+}
+
+func orphanNames(orphans []Orphan) []string {
+	names := make([]string, 0, len(orphans))
+	for _, o := range orphans {
+		names = append(names, o.Kind+"/"+o.Name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+func TestFindOrphansSkipsInstancesByDefault(t *testing.T) {
+	d := newGCTestDriver(t)
+
+	orphans, err := d.FindOrphans(context.Background(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"anti-affinity-group/empty", "security-group/old", "ssh-key/rancher-machine-orphan"}
+	if got := orphanNames(orphans); !slices.Equal(got, want) {
+		t.Errorf("FindOrphans() = %v, want %v", got, want)
+	}
+}
+
+func TestFindOrphansExplicitInstance(t *testing.T) {
+	d := newGCTestDriver(t)
+
+	orphans, err := d.FindOrphans(context.Background(), "ch-gva-2", []string{managedInstanceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := orphanNames(orphans); !slices.Contains(got, "instance/stray") {
+		t.Errorf("FindOrphans() = %v, want the requested instance", got)
+	}
+
+	_, err = d.FindOrphans(context.Background(), "", []string{unmanagedInstanceID})
+	if err == nil || !strings.Contains(err.Error(), "not managed by the driver") {
+		t.Errorf("FindOrphans(unmanaged) error = %v", err)
+	}
+
+	_, err = d.FindOrphans(context.Background(), "", []string{"99999999-9999-9999-9999-999999999999"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("FindOrphans(unknown) error = %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package kubiqo
+
+import (
+	"fmt"
+	"strings"
+
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+const (
+	labelManagedBy   = "managed-by"
+	labelMachineName = "machine-name"
+	managedByValue   = "kubiqo"
+
+	defaultDescription = "created by rancher-machine"
+
+	// sharedGroupDescription describes the security and anti-affinity groups.
+	// They are shared between machines, so only managed-by is recorded.
+	sharedGroupDescription = defaultDescription + " (" + labelManagedBy + "=" + managedByValue + ")"
+)
+
+// parseLabels turns repeated key=value flag values into a label map.
+func parseLabels(values []string) (map[string]string, error) {
+	labels := make(map[string]string, len(values))
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+
+		k, v, ok := strings.Cut(value, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", value)
+		}
+		labels[k] = v
+	}
+
+	return labels, nil
+}
+
+// resourceLabels returns the user labels merged with the labels the driver
+// uses to recognize the resources it owns. Automatic labels take precedence.
+func (d *Driver) resourceLabels() v3.Labels {
+	labels := make(v3.Labels, len(d.Labels)+2)
+	for k, v := range d.Labels {
+		labels[k] = v
+	}
+	labels[labelManagedBy] = managedByValue
+	labels[labelMachineName] = d.MachineName
+
+	return labels
+}
@@ -0,0 +1,50 @@
+package kubiqo
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+func TestParseLabels(t *testing.T) {
+	got, err := parseLabels([]string{"env=prod", "", "selector=a=b", "note="})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{"env": "prod", "selector": "a=b", "note": ""}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseLabels() = %v, want %v", got, want)
+	}
+
+	for _, value := range []string{"env", "=prod"} {
+		if _, err := parseLabels([]string{value}); err == nil {
+			t.Errorf("parseLabels(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestResourceLabels(t *testing.T) {
+	d := &Driver{
+		BaseDriver: &drivers.BaseDriver{MachineName: "node-1"},
+		Labels:     map[string]string{"cluster": "prod-eu", "managed-by": "someone", "machine-name": "other"},
+	}
+
+	want := v3.Labels{"cluster": "prod-eu", "managed-by": "kubiqo", "machine-name": "node-1"}
+	if got := d.resourceLabels(); !maps.Equal(got, want) {
+		t.Errorf("resourceLabels() = %v, want %v", got, want)
+	}
+}
+
+// Groups are shared between machines, their description must not name one.
+func TestSharedGroupDescription(t *testing.T) {
+	if !strings.Contains(sharedGroupDescription, "managed-by=kubiqo") {
+		t.Errorf("sharedGroupDescription = %q, want managed-by=kubiqo", sharedGroupDescription)
+	}
+	if strings.Contains(sharedGroupDescription, labelMachineName) {
+		t.Errorf("sharedGroupDescription = %q records a machine name", sharedGroupDescription)
+	}
+}
@@ -0,0 +1,208 @@
+package kubiqo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// nilUUID references a resource that never exists. Probing an operation
+// against it yields NotFound when the operation is allowed and Forbidden
+// when IAM denies it, without touching any real resource.
+const nilUUID v3.UUID = "00000000-0000-0000-0000-000000000000"
+
+// credentialProbe exercises a single IAM operation needed by the driver.
+// Operations of optional features are only probed when enabled is true.
+type credentialProbe struct {
+	operation string
+	call      func(ctx context.Context, client *v3.Client) error
+	enabled   func(d *Driver) bool
+}
+
+func usesBlockVolumes(d *Driver) bool { return len(d.BlockVolumes) > 0 }
+func usesSnapshots(d *Driver) bool    { return d.SnapshotOnRemove || d.FromSnapshot != "" }
+func usesDeployTarget(d *Driver) bool { return d.DeployTarget != "" }
+func usesReverseDNS(d *Driver) bool   { return d.ReverseDNS != "" }
+func usesDNSDomain(d *Driver) bool    { return d.DNSDomain != "" }
+
+var credentialProbes = []credentialProbe{
+	{"list-templates", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.ListTemplates(ctx)
+		return err
+	}, nil},
+	{"list-instance-types", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.ListInstanceTypes(ctx)
+		return err
+	}, nil},
+	{"list-instances", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.ListInstances(ctx)
+		return err
+	}, nil},
+	{"get-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.GetInstance(ctx, nilUUID)
+		return err
+	}, nil},
+	{"create-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.CreateInstance(ctx, v3.CreateInstanceRequest{})
+		return err
+	}, nil},
+	{"start-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.StartInstance(ctx, nilUUID, v3.StartInstanceRequest{})
+		return err
+	}, nil},
+	{"stop-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.StopInstance(ctx, nilUUID)
+		return err
+	}, nil},
+	{"reboot-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.RebootInstance(ctx, nilUUID)
+		return err
+	}, nil},
+	{"delete-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.DeleteInstance(ctx, nilUUID)
+		return err
+	}, nil},
+	{"list-security-groups", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.ListSecurityGroups(ctx)
+		return err
+	}, nil},
+	{"create-security-group", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.CreateSecurityGroup(ctx, v3.CreateSecurityGroupRequest{})
+		return err
+	}, nil},
+	{"add-rule-to-security-group", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.AddRuleToSecurityGroup(ctx, nilUUID, v3.AddRuleToSecurityGroupRequest{})
+		return err
+	}, nil},
+	{"list-anti-affinity-groups", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.ListAntiAffinityGroups(ctx)
+		return err
+	}, nil},
+	{"create-anti-affinity-group", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.CreateAntiAffinityGroup(ctx, v3.CreateAntiAffinityGroupRequest{})
+		return err
+	}, nil},
+	{"get-ssh-key", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.GetSSHKey(ctx, string(nilUUID))
+		return err
+	}, nil},
+	{"register-ssh-key", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.RegisterSSHKey(ctx, v3.RegisterSSHKeyRequest{})
+		return err
+	}, nil},
+	{"delete-ssh-key", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.DeleteSSHKey(ctx, string(nilUUID))
+		return err
+	}, nil},
+	{"create-block-storage-volume", func(ctx context.Context, c *v3.Client) error {
+		// Restoring a nonexistent snapshot cannot create a volume.
+		_, err := c.CreateBlockStorageVolume(ctx, v3.CreateBlockStorageVolumeRequest{
+			BlockStorageSnapshot: &v3.BlockStorageSnapshotTarget{ID: nilUUID},
+		})
+		return err
+	}, usesBlockVolumes},
+	{"get-block-storage-volume", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.GetBlockStorageVolume(ctx, nilUUID)
+		return err
+	}, usesBlockVolumes},
+	{"attach-block-storage-volume-to-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.AttachBlockStorageVolumeToInstance(ctx, nilUUID, v3.AttachBlockStorageVolumeToInstanceRequest{})
+		return err
+	}, usesBlockVolumes},
+	{"detach-block-storage-volume", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.DetachBlockStorageVolume(ctx, nilUUID)
+		return err
+	}, usesBlockVolumes},
+	{"delete-block-storage-volume", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.DeleteBlockStorageVolume(ctx, nilUUID)
+		return err
+	}, usesBlockVolumes},
+	{"create-snapshot", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.CreateSnapshot(ctx, nilUUID)
+		return err
+	}, usesSnapshots},
+	{"promote-snapshot-to-template", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.PromoteSnapshotToTemplate(ctx, nilUUID, v3.PromoteSnapshotToTemplateRequest{})
+		return err
+	}, usesSnapshots},
+	{"get-template", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.GetTemplate(ctx, nilUUID)
+		return err
+	}, usesSnapshots},
+	{"get-snapshot", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.GetSnapshot(ctx, nilUUID)
+		return err
+	}, func(d *Driver) bool { return d.FromSnapshot != "" }},
+	{"list-deploy-targets", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.ListDeployTargets(ctx)
+		return err
+	}, usesDeployTarget},
+	{"update-reverse-dns-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.UpdateReverseDNSInstance(ctx, nilUUID, v3.UpdateReverseDNSInstanceRequest{})
+		return err
+	}, usesReverseDNS},
+	{"delete-reverse-dns-instance", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.DeleteReverseDNSInstance(ctx, nilUUID)
+		return err
+	}, usesReverseDNS},
+	{"list-dns-domains", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.ListDNSDomains(ctx)
+		return err
+	}, usesDNSDomain},
+	{"create-dns-domain-record", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.CreateDNSDomainRecord(ctx, nilUUID, v3.CreateDNSDomainRecordRequest{})
+		return err
+	}, usesDNSDomain},
+	{"delete-dns-domain-record", func(ctx context.Context, c *v3.Client) error {
+		_, err := c.DeleteDNSDomainRecord(ctx, nilUUID, nilUUID)
+		return err
+	}, usesDNSDomain},
+}
+
+// CheckCredentials verifies that the configured API credentials are valid
+// and allowed to perform every operation the driver relies on. Probes are
+// either read-only or sent with an invalid payload or a nonexistent
+// resource, so that the API rejects them after IAM evaluation.
+func (d *Driver) CheckCredentials(ctx context.Context) error {
+	client, err := d.client(ctx)
+	switch {
+	case errors.Is(err, v3.ErrUnauthorized):
+		return fmt.Errorf("invalid exoscale API credentials: %w", err)
+	case errors.Is(err, v3.ErrForbidden):
+		return fmt.Errorf("exoscale API key is missing IAM permissions for operations: list-zones")
+	case err != nil:
+		return err
+	}
+
+	var missing []string
+	for _, probe := range credentialProbes {
+		if probe.enabled != nil && !probe.enabled(d) {
+			continue
+		}
+
+		err := probe.call(ctx, client)
+		switch {
+		case err == nil,
+			errors.Is(err, v3.ErrNotFound),
+			errors.Is(err, v3.ErrBadRequest),
+			errors.Is(err, v3.ErrUnprocessableEntity):
+			log.Debugf("Credential check: %s allowed", probe.operation)
+		case errors.Is(err, v3.ErrUnauthorized):
+			return fmt.Errorf("invalid exoscale API credentials: %w", err)
+		case errors.Is(err, v3.ErrForbidden):
+			missing = append(missing, probe.operation)
+		default:
+			log.Warnf("Credential check: unable to verify %s: %s", probe.operation, err)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("exoscale API key is missing IAM permissions for operations: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
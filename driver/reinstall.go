@@ -0,0 +1,136 @@
+package kubiqo
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/docker/machine/libmachine/log"
+	v3 "github.com/exoscale/egoscale/v3"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Reinstall resets the root disk of the instance to image, or to the current
+// image when empty, keeping its ID, IP addresses, security groups and
+// networks. The cloud-init user data is rebuilt as in Create, with the
+// public key of the machine SSH key authorized for the new system.
+func (d *Driver) Reinstall(image string) error {
+	ctx := context.Background()
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	var template v3.Template
+	switch {
+	case image != "":
+		template, err = d.findTemplate(ctx, client, image)
+	case d.FromSnapshot != "":
+		template, err = d.snapshotTemplate(ctx, client)
+	default:
+		template, err = d.findTemplate(ctx, client, d.Image)
+	}
+	if err != nil {
+		return err
+	}
+
+	if templateSize := template.Size >> 30; d.DiskSize < templateSize {
+		return fmt.Errorf("invalid disk size %d, image %s requires at least %d GiB", d.DiskSize, template.Name, templateSize)
+	}
+
+	if err := d.checkSecurityFeatures(template); err != nil {
+		return err
+	}
+
+	cloudInit, err := d.getCloudInit()
+	if err != nil {
+		return err
+	}
+
+	cloudInit, err = d.appendBlockVolumesCloudInit(cloudInit)
+	if err != nil {
+		return err
+	}
+
+	// The key pair registered by Create is gone, authorize the machine key
+	// through cloud-init in every case.
+	pubKey, err := publicKeyFromPrivate(d.GetSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	authorizedKeys, err := d.resolveAuthorizedKeys(ctx)
+	if err != nil {
+		return err
+	}
+	cloudInit, err = appendAuthorizedKeys(cloudInit, append(authorizedKeys, pubKey)...)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Using the following cloud-init file:")
+	log.Debugf("%s", string(cloudInit))
+
+	op, err := client.UpdateInstance(ctx, d.ID, v3.UpdateInstanceRequest{
+		Labels:   d.resourceLabels(),
+		UserData: base64.StdEncoding.EncodeToString(cloudInit),
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
+
+	log.Infof("Reinstalling %s with %s...", d.MachineName, template.Name)
+	op, err = client.ResetInstance(ctx, d.ID, v3.ResetInstanceRequest{
+		Template: &template,
+		DiskSize: d.DiskSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
+
+	if image != "" {
+		d.Image = image
+		d.FromSnapshot = ""
+	}
+	d.UserData = cloudInit
+	if template.DefaultUser != "" {
+		d.SSHUser = template.DefaultUser
+	}
+
+	// The new system comes with new host keys.
+	d.SSHHostKey = ""
+	if err := d.waitForSSHReady(); err != nil {
+		return err
+	}
+
+	if d.WaitCloudInit {
+		return d.waitForCloudInit()
+	}
+
+	return nil
+}
+
+// publicKeyFromPrivate returns the authorized_keys line of the private key
+// stored at path.
+func publicKeyFromPrivate(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := gossh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse SSH private key %s: %w", path, err)
+	}
+
+	return gossh.MarshalAuthorizedKey(signer.PublicKey()), nil
+}
@@ -0,0 +1,66 @@
+package kubiqo
+
+import (
+	"context"
+
+	"github.com/docker/machine/libmachine/log"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// EnterRescue stops the instance and boots it into the rescue profile. When
+// profile is empty, netboot-efi is used for UEFI templates and netboot
+// otherwise. While in rescue, GetState reports state.Paused.
+func (d *Driver) EnterRescue(profile string) error {
+	ctx := context.Background()
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	if profile == "" {
+		instance, err := d.getInstance()
+		if err != nil {
+			return err
+		}
+
+		profile = string(v3.StartInstanceRequestRescueProfileNetboot)
+		if instance.Template != nil {
+			template, err := client.GetTemplate(ctx, instance.Template.ID)
+			if err == nil && template.BootMode == v3.TemplateBootModeUefi {
+				profile = string(v3.StartInstanceRequestRescueProfileNetbootEfi)
+			}
+		}
+	}
+
+	log.Infof("Stopping %s...", d.MachineName)
+	if err := d.Stop(); err != nil {
+		return err
+	}
+
+	log.Infof("Booting %s into the %s rescue profile...", d.MachineName, profile)
+	op, err := client.StartInstance(ctx, d.ID, v3.StartInstanceRequest{
+		RescueProfile: v3.StartInstanceRequestRescueProfile(profile),
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
+
+	d.InRescue = true
+
+	return nil
+}
+
+// ExitRescue stops the instance and boots it normally again.
+func (d *Driver) ExitRescue() error {
+	log.Infof("Stopping %s...", d.MachineName)
+	if err := d.Stop(); err != nil {
+		return err
+	}
+
+	log.Infof("Starting %s...", d.MachineName)
+	return d.Start()
+}
@@ -0,0 +1,58 @@
+package kubiqo
+
+import (
+	"context"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// Scale changes the instance type of the instance to profile. The instance
+// is stopped for the change, started again if it was running and
+// d.InstanceProfile is updated on success.
+func (d *Driver) Scale(profile string) error {
+	ctx := context.Background()
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	instType, err := d.findInstanceType(ctx, client, profile)
+	if err != nil {
+		return err
+	}
+
+	st, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if st == state.Running {
+		log.Infof("Stopping %s...", d.MachineName)
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Scaling %s to %s...", d.MachineName, profile)
+	op, err := client.ScaleInstance(ctx, d.ID, v3.ScaleInstanceRequest{
+		InstanceType: &instType,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
+
+	d.InstanceProfile = profile
+
+	if st != state.Running {
+		return nil
+	}
+
+	log.Infof("Starting %s...", d.MachineName)
+	return d.Start()
+}
@@ -0,0 +1,238 @@
+package kubiqo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/state"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+// snapshotTemplatePrefix names the templates promoted from snapshots.
+const snapshotTemplatePrefix = "rancher-machine-snapshot-"
+
+// CreateSnapshot takes a snapshot of the root disk of the instance.
+func (d *Driver) CreateSnapshot() (*v3.Snapshot, error) {
+	ctx := context.Background()
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.createSnapshot(ctx, client)
+}
+
+func (d *Driver) createSnapshot(ctx context.Context, client *v3.Client) (*v3.Snapshot, error) {
+	log.Infof("Taking a snapshot of %s...", d.MachineName)
+
+	op, err := client.CreateSnapshot(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Wait(ctx, op, v3.OperationStateSuccess)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetSnapshot(ctx, res.Reference.ID)
+}
+
+// ListSnapshots returns the snapshots of the instance.
+func (d *Driver) ListSnapshots() ([]v3.Snapshot, error) {
+	ctx := context.Background()
+	client, err := d.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := client.ListSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []v3.Snapshot
+	for _, snapshot := range list.Snapshots {
+		if snapshot.Instance != nil && snapshot.Instance.ID == d.ID {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	return snapshots, nil
+}
+
+// RevertToSnapshot restores the root disk of the instance from one of its
+// snapshots. The instance is stopped for the operation and started again if
+// it was running.
+func (d *Driver) RevertToSnapshot(id v3.UUID) error {
+	ctx := context.Background()
+	client, err := d.client(ctx)
+	if err != nil {
+		return err
+	}
+
+	st, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if st == state.Running {
+		log.Infof("Stopping %s...", d.MachineName)
+		if err := d.Stop(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Reverting %s to snapshot %s...", d.MachineName, id)
+	op, err := client.RevertInstanceToSnapshot(ctx, d.ID, v3.RevertInstanceToSnapshotRequest{
+		ID: id,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.Wait(ctx, op, v3.OperationStateSuccess); err != nil {
+		return err
+	}
+
+	if st != state.Running {
+		return nil
+	}
+
+	log.Infof("Starting %s...", d.MachineName)
+	return d.Start()
+}
+
+// snapshotTemplate returns the private template built from the snapshot
+// d.FromSnapshot, promoting the snapshot on first use. The template is kept
+// so that further machines cloned from the same snapshot start right away.
+func (d *Driver) snapshotTemplate(ctx context.Context, client *v3.Client) (v3.Template, error) {
+	template, err := d.promotedTemplate(ctx, client)
+	if err != nil {
+		return v3.Template{}, err
+	}
+	if template != nil {
+		log.Debugf("Reusing template %s for snapshot %s", template.ID, d.FromSnapshot)
+		return *template, nil
+	}
+
+	snapshot, err := client.GetSnapshot(ctx, v3.UUID(d.FromSnapshot))
+	if err != nil {
+		return v3.Template{}, err
+	}
+
+	tpl, err := promoteSnapshot(ctx, client, snapshot)
+	if err != nil {
+		return v3.Template{}, err
+	}
+
+	return *tpl, nil
+}
+
+// promoteSnapshot creates the private template of snapshot, named so that
+// snapshotTemplate finds it for --exoscale-from-snapshot. Unlike the
+// snapshot, the template outlives the instance.
+func promoteSnapshot(ctx context.Context, client *v3.Client, snapshot *v3.Snapshot) (*v3.Template, error) {
+	req := v3.PromoteSnapshotToTemplateRequest{
+		Name:        snapshotTemplatePrefix + string(snapshot.ID),
+		Description: fmt.Sprintf("%s from snapshot %s", defaultDescription, snapshot.ID),
+	}
+
+	// Carry the login settings over from the source template when the
+	// snapshotted instance still exists.
+	if source := sourceTemplate(ctx, client, snapshot); source != nil {
+		req.DefaultUser = source.DefaultUser
+		req.PasswordEnabled = source.PasswordEnabled
+		req.SSHKeyEnabled = source.SSHKeyEnabled
+	}
+
+	log.Infof("Creating a template from snapshot %s...", snapshot.ID)
+	op, err := client.PromoteSnapshotToTemplate(ctx, snapshot.ID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Wait(ctx, op, v3.OperationStateSuccess)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetTemplate(ctx, res.Reference.ID)
+}
+
+// sourceTemplate returns the template of the snapshotted instance, or nil
+// when the instance or its template no longer exist.
+func sourceTemplate(ctx context.Context, client *v3.Client, snapshot *v3.Snapshot) *v3.Template {
+	if snapshot.Instance == nil {
+		return nil
+	}
+
+	instance, err := client.GetInstance(ctx, snapshot.Instance.ID)
+	if err != nil || instance.Template == nil {
+		return nil
+	}
+
+	template, err := client.GetTemplate(ctx, instance.Template.ID)
+	if err != nil {
+		return nil
+	}
+
+	return template
+}
+
+// promotedTemplate returns the template promoted from the snapshot
+// d.FromSnapshot, or nil when it was not promoted yet.
+func (d *Driver) promotedTemplate(ctx context.Context, client *v3.Client) (*v3.Template, error) {
+	templates, err := client.ListTemplates(ctx, v3.ListTemplatesWithVisibility(v3.ListTemplatesVisibilityPrivate))
+	if err != nil {
+		return nil, err
+	}
+
+	template, err := templates.FindTemplate(snapshotTemplatePrefix + d.FromSnapshot)
+	if errors.Is(err, v3.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+// checkSnapshotSource verifies that a machine can be created from
+// d.FromSnapshot with the requested disk size and security features. The
+// snapshot itself is only required until it is promoted to a template,
+// since it is deleted along with its instance.
+func (d *Driver) checkSnapshotSource(ctx context.Context, client *v3.Client) error {
+	template, err := d.promotedTemplate(ctx, client)
+	if err != nil {
+		return err
+	}
+
+	if template != nil {
+		if templateSize := template.Size >> 30; d.DiskSize < templateSize {
+			return fmt.Errorf("invalid disk size %d, template %s requires at least %d GiB", d.DiskSize, template.Name, templateSize)
+		}
+
+		return d.checkSecurityFeatures(*template)
+	}
+
+	snapshot, err := client.GetSnapshot(ctx, v3.UUID(d.FromSnapshot))
+	if err != nil {
+		return fmt.Errorf("unable to find snapshot %s: %w", d.FromSnapshot, err)
+	}
+
+	if d.DiskSize < snapshot.Size {
+		return fmt.Errorf("invalid disk size %d, snapshot %s requires at least %d GiB", d.DiskSize, snapshot.ID, snapshot.Size)
+	}
+
+	if source := sourceTemplate(ctx, client, snapshot); source != nil {
+		return d.checkSecurityFeatures(*source)
+	}
+
+	log.Debugf("Boot mode of snapshot %s unknown until its template is created", snapshot.ID)
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package kubiqo
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const sshProbeTimeout = 10 * time.Second
+
+// waitForSSHReady waits until the instance accepts SSH connections with the
+// machine key. On failure, the error tells whether the port is unreachable
+// or the key was refused, and whether the host key changed. On success the
+// host key fingerprint is recorded to detect later changes.
+func (d *Driver) waitForSSHReady() error {
+	log.Infof("Waiting for SSH on %s...", d.MachineName)
+
+	if err := drivers.WaitForSSH(d); err != nil {
+		if _, diag := d.probeSSH(); diag != "" {
+			return fmt.Errorf("%s: %s", diag, err)
+		}
+		return err
+	}
+
+	hostKey, diag := d.probeSSH()
+	if hostKey == "" {
+		return fmt.Errorf("SSH became unavailable: %s", diag)
+	}
+	if d.SSHHostKey != "" && d.SSHHostKey != hostKey {
+		log.Warnf("SSH host key of %s changed from %s to %s", d.MachineName, d.SSHHostKey, hostKey)
+	}
+	d.SSHHostKey = hostKey
+
+	return nil
+}
+
+// probeSSH connects to the instance with the machine key and returns the
+// SHA256 fingerprint of its host key, or an empty fingerprint and the
+// diagnosis of the failure.
+func (d *Driver) probeSSH() (string, string) {
+	ip, err := d.GetSSHHostname()
+	if err != nil {
+		return "", fmt.Sprintf("no IP address: %s", err)
+	}
+
+	port, err := d.GetSSHPort()
+	if err != nil {
+		return "", err.Error()
+	}
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	conn, err := net.DialTimeout("tcp", address, sshProbeTimeout)
+	if err != nil {
+		return "", fmt.Sprintf("TCP port %s is not reachable, check the security groups: %s", address, err)
+	}
+	defer conn.Close()
+
+	key, err := os.ReadFile(d.GetSSHKeyPath())
+	if err != nil {
+		return "", fmt.Sprintf("cannot read SSH private key: %s", err)
+	}
+
+	signer, err := gossh.ParsePrivateKey(key)
+	if err != nil {
+		return "", fmt.Sprintf("cannot parse SSH private key %s: %s", d.GetSSHKeyPath(), err)
+	}
+
+	var hostKey string
+	config := &gossh.ClientConfig{
+		User: d.GetSSHUsername(),
+		Auth: []gossh.AuthMethod{gossh.PublicKeys(signer)},
+		HostKeyCallback: func(_ string, _ net.Addr, key gossh.PublicKey) error {
+			hostKey = gossh.FingerprintSHA256(key)
+			return nil
+		},
+		Timeout: sshProbeTimeout,
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(sshProbeTimeout))
+	c, chans, reqs, err := gossh.NewClientConn(conn, address, config)
+	if err == nil {
+		gossh.NewClient(c, chans, reqs).Close()
+		return hostKey, ""
+	}
+
+	var diag string
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		diag = fmt.Sprintf("SSH authentication as %s with key %s was refused, check the public key is authorized for that user", d.GetSSHUsername(), d.GetSSHKeyPath())
+	} else {
+		diag = fmt.Sprintf("SSH handshake with %s failed: %s", address, err)
+	}
+
+	if hostKey != "" && d.SSHHostKey != "" && hostKey != d.SSHHostKey {
+		diag += fmt.Sprintf(" (the host key changed from %s to %s)", d.SSHHostKey, hostKey)
+	}
+
+	return "", diag
+}
+
+// runSSHCommand runs command on the instance with the machine key. Unlike
+// drivers.RunSSHCommandFromDriver, the connection, the handshake and the
+// command are all bounded by ctx.
+func (d *Driver) runSSHCommand(ctx context.Context, command string) error {
+	ip, err := d.GetSSHHostname()
+	if err != nil {
+		return err
+	}
+
+	port, err := d.GetSSHPort()
+	if err != nil {
+		return err
+	}
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+
+	key, err := os.ReadFile(d.GetSSHKeyPath())
+	if err != nil {
+		return err
+	}
+
+	signer, err := gossh.ParsePrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	config := &gossh.ClientConfig{
+		User:            d.GetSSHUsername(),
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(signer)},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+	}
+
+	c, chans, reqs, err := gossh.NewClientConn(conn, address, config)
+	if err != nil {
+		return err
+	}
+	client := gossh.NewClient(c, chans, reqs)
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	return session.Run(command)
+}
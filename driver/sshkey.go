@@ -0,0 +1,116 @@
+package kubiqo
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/docker/machine/libmachine/ssh"
+	v3 "github.com/exoscale/egoscale/v3"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	sshKeyTypeRSA     = "rsa"
+	sshKeyTypeED25519 = "ed25519"
+	sshKeyTypeECDSA   = "ecdsa"
+)
+
+// sshKeyFileNames are the private key file names in the machine directory,
+// following the OpenSSH naming.
+var sshKeyFileNames = map[string]string{
+	sshKeyTypeRSA:     "id_rsa",
+	sshKeyTypeED25519: "id_ed25519",
+	sshKeyTypeECDSA:   "id_ecdsa",
+}
+
+func validateSSHKeyType(keyType string) error {
+	if _, ok := sshKeyFileNames[keyType]; !ok {
+		return fmt.Errorf("invalid SSH key type %q, expected one of rsa, ed25519 or ecdsa", keyType)
+	}
+
+	return nil
+}
+
+// generateSSHKey writes a new key pair of the configured type to the machine
+// directory, the public key alongside with a .pub extension, and returns the
+// path of the private key.
+func (d *Driver) generateSSHKey() (string, error) {
+	keyType := d.SSHKeyType
+	if keyType == "" {
+		keyType = sshKeyTypeRSA
+	}
+	if err := validateSSHKeyType(keyType); err != nil {
+		return "", err
+	}
+
+	d.SSHKeyPath = d.ResolveStorePath(sshKeyFileNames[keyType])
+	if keyType == sshKeyTypeRSA {
+		return d.SSHKeyPath, ssh.GenerateSSHKey(d.SSHKeyPath)
+	}
+
+	var (
+		private crypto.PrivateKey
+		public  crypto.PublicKey
+	)
+	switch keyType {
+	case sshKeyTypeED25519:
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		private, public = priv, pub
+	case sshKeyTypeECDSA:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		private, public = priv, &priv.PublicKey
+	}
+
+	block, err := gossh.MarshalPrivateKey(private, "")
+	if err != nil {
+		return "", fmt.Errorf("error marshalling SSH private key: %w", err)
+	}
+
+	sshPublic, err := gossh.NewPublicKey(public)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling SSH public key: %w", err)
+	}
+
+	if err := os.WriteFile(d.SSHKeyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return "", err
+	}
+
+	if err := os.WriteFile(d.SSHKeyPath+".pub", gossh.MarshalAuthorizedKey(sshPublic), 0600); err != nil {
+		return "", err
+	}
+
+	return d.SSHKeyPath, nil
+}
+
+// findSSHKeyPairs resolves the SSH keys registered in the organization that
+// are attached to the instance in addition to the driver key.
+func (d *Driver) findSSHKeyPairs(ctx context.Context, client *v3.Client) ([]v3.SSHKey, error) {
+	keys := make([]v3.SSHKey, 0, len(d.SSHKeyPairNames))
+	for _, name := range d.SSHKeyPairNames {
+		key, err := client.GetSSHKey(ctx, name)
+		if errors.Is(err, v3.ErrNotFound) {
+			return nil, fmt.Errorf("SSH key %s is not registered", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, *key)
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,55 @@
+package kubiqo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/state"
+	v3 "github.com/exoscale/egoscale/v3"
+)
+
+func TestInstanceState(t *testing.T) {
+	tests := []struct {
+		api      v3.InstanceState
+		inRescue bool
+		want     state.State
+	}{
+		{v3.InstanceStateStarting, false, state.Starting},
+		{v3.InstanceStateRunning, false, state.Running},
+		{v3.InstanceStateRunning, true, state.Paused},
+		{v3.InstanceStateMigrating, false, state.Running},
+		{v3.InstanceStateStopping, false, state.Stopping},
+		{v3.InstanceStateDestroying, false, state.Stopping},
+		{v3.InstanceStateStopped, true, state.Stopped},
+		{v3.InstanceStateError, false, state.Error},
+		{"hibernating", false, state.None},
+	}
+
+	for _, tt := range tests {
+		d := &Driver{ID: "11111111-1111-1111-1111-111111111111", InRescue: tt.inRescue}
+
+		got, err := d.instanceState(tt.api)
+		if err != nil {
+			t.Errorf("instanceState(%q) error = %v", tt.api, err)
+		}
+		if got != tt.want {
+			t.Errorf("instanceState(%q, rescue=%t) = %s, want %s", tt.api, tt.inRescue, got, tt.want)
+		}
+	}
+}
+
+func TestInstanceStateGone(t *testing.T) {
+	d := &Driver{ID: "11111111-1111-1111-1111-111111111111"}
+
+	for _, api := range []v3.InstanceState{v3.InstanceStateDestroyed, v3.InstanceStateExpunging} {
+		got, err := d.instanceState(api)
+		if got != state.None {
+			t.Errorf("instanceState(%q) = %s, want None", api, got)
+		}
+
+		var notFound *InstanceNotFoundError
+		if !errors.As(err, &notFound) || !errors.Is(err, v3.ErrNotFound) {
+			t.Errorf("instanceState(%q) error = %v, want an InstanceNotFoundError", api, err)
+		}
+	}
+}
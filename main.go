@@ -1,10 +1,17 @@
 package main
 
 import (
+	"os"
+
 	"github.com/docker/machine/libmachine/drivers/plugin"
+	"github.com/francoismeulenberg/docker-machine-driver-kubiqo/commands"
 	kubiqo "github.com/francoismeulenberg/docker-machine-driver-kubiqo/driver"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(commands.Run(os.Args[1:]))
+	}
+
 	plugin.RegisterDriver(kubiqo.NewDriver("", ""))
 }